@@ -0,0 +1,103 @@
+/*
+** Zabbix
+** Copyright 2001-2022 Zabbix SIA
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+**     http://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+**/
+
+package plugin
+
+import (
+	"fmt"
+	"golang.zabbix.com/sdk/zbxerr"
+	"time"
+)
+
+const (
+	// breakerThreshold 连续连接失败达到该次数后断路器打开
+	breakerThreshold = 3
+
+	// 断路器打开后的等待时间从breakerMinBackoff开始，每次探测失败翻倍，最长breakerMaxBackoff
+	breakerMinBackoff = 5 * time.Second
+	breakerMaxBackoff = 5 * time.Minute
+)
+
+const (
+	circuitClosed   = "closed"
+	circuitOpen     = "open"
+	circuitHalfOpen = "half-open"
+)
+
+// circuitBreaker 记录到同一连接地址的连续连接失败，打开期间直接拒绝建立连接
+// 等待时间结束后进入半开状态，只允许一个请求探测，成功则关闭，失败则以更长的等待时间重新打开
+type circuitBreaker struct {
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+// allow 返回是否可以尝试建立连接，半开状态下只放行一个探测请求
+func (b *circuitBreaker) allow(now time.Time) error {
+	if b.failures < breakerThreshold {
+		return nil
+	}
+
+	if now.Before(b.openUntil) || b.probing {
+		return zbxerr.ErrorConnectionFailed.Wrap(fmt.Errorf(
+			"circuit is open after %d consecutive connection failures, next attempt in %s",
+			b.failures, b.openUntil.Sub(now).Truncate(time.Second)))
+	}
+
+	b.probing = true
+
+	return nil
+}
+
+// done 记录连接尝试的结果
+func (b *circuitBreaker) done(success bool, now time.Time) {
+	b.probing = false
+
+	if success {
+		b.failures = 0
+		b.openUntil = time.Time{}
+
+		return
+	}
+
+	b.failures++
+
+	if b.failures < breakerThreshold {
+		return
+	}
+
+	backoff := breakerMaxBackoff
+	if shift := b.failures - breakerThreshold; shift < 10 {
+		if d := breakerMinBackoff << shift; d < backoff {
+			backoff = d
+		}
+	}
+
+	b.openUntil = now.Add(backoff)
+}
+
+// state 返回断路器当前的状态
+func (b *circuitBreaker) state(now time.Time) string {
+	switch {
+	case b.failures < breakerThreshold:
+		return circuitClosed
+	case now.Before(b.openUntil):
+		return circuitOpen
+	default:
+		return circuitHalfOpen
+	}
+}
@@ -0,0 +1,183 @@
+/*
+** Zabbix
+** Copyright 2001-2022 Zabbix SIA
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+**     http://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+**/
+
+package plugin
+
+import (
+	"testing"
+	"time"
+)
+
+// fail 记录n次失败的连接尝试
+func fail(b *circuitBreaker, n int, now time.Time) {
+	for i := 0; i < n; i++ {
+		b.done(false, now)
+	}
+}
+
+func TestCircuitBreakerThreshold(t *testing.T) {
+	var b circuitBreaker
+
+	now := time.Now()
+
+	for i := 0; i < breakerThreshold-1; i++ {
+		if err := b.allow(now); err != nil {
+			t.Fatalf("allow() after %d failures = %v, want nil", i, err)
+		}
+
+		b.done(false, now)
+	}
+
+	if s := b.state(now); s != circuitClosed {
+		t.Errorf("state() below threshold = %s, want %s", s, circuitClosed)
+	}
+
+	if err := b.allow(now); err != nil {
+		t.Fatalf("allow() below threshold = %v, want nil", err)
+	}
+
+	b.done(false, now)
+
+	if s := b.state(now); s != circuitOpen {
+		t.Errorf("state() at threshold = %s, want %s", s, circuitOpen)
+	}
+
+	if err := b.allow(now.Add(breakerMinBackoff - time.Millisecond)); err == nil {
+		t.Error("allow() while open = nil, want error")
+	}
+
+	if s := b.state(now.Add(breakerMinBackoff)); s != circuitHalfOpen {
+		t.Errorf("state() after backoff = %s, want %s", s, circuitHalfOpen)
+	}
+}
+
+func TestCircuitBreakerBackoff(t *testing.T) {
+	var b circuitBreaker
+
+	now := time.Now()
+	fail(&b, breakerThreshold, now)
+
+	for want := breakerMinBackoff; want < breakerMaxBackoff; want *= 2 {
+		if got := b.openUntil.Sub(now); got != want {
+			t.Fatalf("backoff after %d failures = %s, want %s", b.failures, got, want)
+		}
+
+		b.done(false, now)
+	}
+
+	if got := b.openUntil.Sub(now); got != breakerMaxBackoff {
+		t.Errorf("backoff after %d failures = %s, want %s", b.failures, got, breakerMaxBackoff)
+	}
+
+	// 失败次数很多时移位不得溢出
+	fail(&b, 100, now)
+
+	if got := b.openUntil.Sub(now); got != breakerMaxBackoff {
+		t.Errorf("backoff after %d failures = %s, want %s", b.failures, got, breakerMaxBackoff)
+	}
+}
+
+func TestCircuitBreakerSingleProbe(t *testing.T) {
+	var b circuitBreaker
+
+	now := time.Now()
+	fail(&b, breakerThreshold, now)
+
+	probe := now.Add(breakerMinBackoff)
+
+	if err := b.allow(probe); err != nil {
+		t.Fatalf("allow() for probe = %v, want nil", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := b.allow(probe); err == nil {
+			t.Fatal("allow() during probe = nil, want error")
+		}
+	}
+
+	// 探测失败后以加倍的等待时间重新打开
+	b.done(false, probe)
+
+	if got := b.openUntil.Sub(probe); got != 2*breakerMinBackoff {
+		t.Errorf("backoff after failed probe = %s, want %s", got, 2*breakerMinBackoff)
+	}
+
+	if err := b.allow(probe); err == nil {
+		t.Error("allow() after failed probe = nil, want error")
+	}
+
+	if err := b.allow(probe.Add(2 * breakerMinBackoff)); err != nil {
+		t.Errorf("allow() for next probe = %v, want nil", err)
+	}
+}
+
+func TestCircuitBreakerReset(t *testing.T) {
+	var b circuitBreaker
+
+	now := time.Now()
+	fail(&b, breakerThreshold+2, now)
+
+	probe := b.openUntil
+
+	if err := b.allow(probe); err != nil {
+		t.Fatalf("allow() for probe = %v, want nil", err)
+	}
+
+	b.done(true, probe)
+
+	if s := b.state(probe); s != circuitClosed {
+		t.Errorf("state() after successful probe = %s, want %s", s, circuitClosed)
+	}
+
+	if b.failures != 0 || b.probing || !b.openUntil.IsZero() {
+		t.Errorf("breaker after successful probe = %+v, want zero value", b)
+	}
+
+	// 恢复后重新从头计数，阈值以下不打开
+	fail(&b, breakerThreshold-1, probe)
+
+	if err := b.allow(probe); err != nil {
+		t.Errorf("allow() after reset = %v, want nil", err)
+	}
+}
+
+func TestConnectExistingConnectionEndsProbe(t *testing.T) {
+	const addr = "oracle://db"
+
+	now := time.Now()
+	b := &circuitBreaker{}
+	fail(b, breakerThreshold, now)
+
+	if err := b.allow(b.openUntil); err != nil {
+		t.Fatalf("allow() for probe = %v, want nil", err)
+	}
+
+	existing := &OracleConn{addr: addr}
+	c := &ConnManager{
+		connections: map[string]*OracleConn{addr: existing},
+		breakers:    map[string]*circuitBreaker{addr: b},
+	}
+
+	conn, err := c.connect(addr, b)
+	if err != nil || conn != existing {
+		t.Fatalf("connect() = %v, %v, want the existing connection", conn, err)
+	}
+
+	if b.probing || b.state(now) != circuitClosed {
+		t.Errorf("breaker after connect() = %+v, want closed", *b)
+	}
+}
@@ -0,0 +1,84 @@
+/*
+** Zabbix
+** Copyright 2001-2022 Zabbix SIA
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+**     http://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+**/
+
+package plugin
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"github.com/892294101/zabbix-agent2-oracle/plugin/handlers"
+	"golang.zabbix.com/sdk/metric"
+)
+
+// catalogueData 声明式指标目录，每个条目由handlers.CatalogueMetric的通用处理程序执行
+// 需要复杂逻辑的指标仍在metricHandlers和metrics中以Go处理程序注册
+//
+//go:embed catalogue.json
+var catalogueData []byte
+
+// loadCatalogue 将目录中的指标加入给定的指标集和处理程序表，键不能与已有指标重复
+// 标记为collect的指标同时加入collectedMetrics
+func loadCatalogue(ms metric.MetricSet, hs map[string]handlerFunc) error {
+	var defs []*handlers.CatalogueMetric
+
+	if err := json.Unmarshal(catalogueData, &defs); err != nil {
+		return fmt.Errorf("cannot parse metric catalogue: %w", err)
+	}
+
+	for _, def := range defs {
+		if err := def.Validate(); err != nil {
+			return err
+		}
+
+		if _, ok := ms[def.Key]; ok {
+			return fmt.Errorf("catalogue metric %q is already registered", def.Key)
+		}
+
+		params := []*metric.Param{paramURI}
+		for _, p := range def.Params {
+			params = append(params, newCatalogueParam(p))
+		}
+
+		ms[def.Key] = metric.New(def.Description, params, false)
+		hs[def.Key] = def.Handler
+
+		if def.Collect {
+			collectedMetrics[def.Key] = true
+		}
+	}
+
+	return nil
+}
+
+func newCatalogueParam(def handlers.CatalogueParam) *metric.Param {
+	p := metric.NewParam(def.Name, def.Description)
+
+	if def.Required {
+		p.SetRequired()
+	} else if def.Default != "" {
+		p.WithDefault(def.Default)
+	}
+
+	if len(def.Values) > 0 {
+		p.WithValidator(metric.SetValidator{Set: def.Values})
+	} else if def.Min != nil && def.Max != nil {
+		p.WithValidator(metric.RangeValidator{Min: *def.Min, Max: *def.Max})
+	}
+
+	return p
+}
@@ -0,0 +1,76 @@
+/*
+** Zabbix
+** Copyright 2001-2022 Zabbix SIA
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+**     http://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+**/
+
+package plugin
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// call 一次正在执行的查询，完成后由所有等待者共享结果
+type call struct {
+	wg     sync.WaitGroup
+	result interface{}
+	err    error
+}
+
+// callGroup 合并相同请求的并发查询，同一时刻每个标识只执行一次
+type callGroup struct {
+	mu        sync.Mutex
+	calls     map[string]*call
+	coalesced uint64
+}
+
+// do 执行fn并返回其结果，已有相同标识的查询在执行时等待并共享该查询的结果
+// shared表示结果来自其他请求发起的查询
+func (g *callGroup) do(id string, fn func() (interface{}, error)) (result interface{}, err error, shared bool) {
+	g.mu.Lock()
+
+	if g.calls == nil {
+		g.calls = make(map[string]*call)
+	}
+
+	if c, ok := g.calls[id]; ok {
+		g.mu.Unlock()
+		atomic.AddUint64(&g.coalesced, 1)
+		c.wg.Wait()
+
+		return c.result, c.err, true
+	}
+
+	c := &call{}
+	c.wg.Add(1)
+	g.calls[id] = c
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, id)
+		g.mu.Unlock()
+		c.wg.Done()
+	}()
+
+	c.result, c.err = fn()
+
+	return c.result, c.err, false
+}
+
+// Coalesced 返回共享了其他请求查询结果的请求总数
+func (g *callGroup) Coalesced() uint64 {
+	return atomic.LoadUint64(&g.coalesced)
+}
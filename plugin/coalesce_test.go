@@ -0,0 +1,117 @@
+/*
+** Zabbix
+** Copyright 2001-2022 Zabbix SIA
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+**     http://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+**/
+
+package plugin
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+func TestCallGroupSharesLeaderResult(t *testing.T) {
+	var (
+		g       callGroup
+		calls   int
+		started = make(chan struct{})
+		finish  = make(chan struct{})
+		errWant = errors.New("query failed")
+	)
+
+	type outcome struct {
+		result interface{}
+		err    error
+		shared bool
+	}
+
+	leader := make(chan outcome)
+
+	go func() {
+		result, err, shared := g.do("id", func() (interface{}, error) {
+			calls++
+			close(started)
+			<-finish
+
+			return "result", errWant
+		})
+		leader <- outcome{result, err, shared}
+	}()
+
+	<-started
+
+	const waiters = 3
+
+	var wg sync.WaitGroup
+
+	res := make([]outcome, waiters)
+
+	for i := 0; i < waiters; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			result, err, shared := g.do("id", func() (interface{}, error) {
+				t.Error("waiter must not run its own query")
+
+				return nil, nil
+			})
+			res[i] = outcome{result, err, shared}
+		}(i)
+	}
+
+	// 等待全部请求进入等待状态后再结束首个查询
+	for g.Coalesced() < waiters {
+		runtime.Gosched()
+	}
+
+	close(finish)
+	wg.Wait()
+
+	got := <-leader
+	if got.shared || got.result != "result" || got.err != errWant {
+		t.Errorf("leader got %+v", got)
+	}
+
+	for i, r := range res {
+		if !r.shared || r.result != "result" || r.err != errWant {
+			t.Errorf("waiter %d got %+v", i, r)
+		}
+	}
+
+	if calls != 1 {
+		t.Errorf("query executed %d times, want 1", calls)
+	}
+}
+
+func TestCallGroupRunsAgainAfterCompletion(t *testing.T) {
+	var g callGroup
+
+	for i := 0; i < 2; i++ {
+		_, _, shared := g.do("id", func() (interface{}, error) {
+			return i, nil
+		})
+		if shared {
+			t.Fatalf("call %d shared a finished query", i)
+		}
+	}
+
+	if n := g.Coalesced(); n != 0 {
+		t.Errorf("Coalesced() = %d, want 0", n)
+	}
+}
@@ -0,0 +1,223 @@
+/*
+** Zabbix
+** Copyright 2001-2022 Zabbix SIA
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+**     http://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+**/
+
+package plugin
+
+import (
+	"context"
+	"github.com/892294101/zabbix-agent2-oracle/plugin/handlers"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cacheEntry 后台采集的一个监控项结果，params和extraParams用于重新执行查询
+type cacheEntry struct {
+	key         string
+	params      map[string]string
+	extraParams []string
+	result      interface{}
+	updated     time.Time
+	requested   time.Time
+}
+
+// resultCache 保存可后台采集的监控项最近一次的结果，以键、参数和连接地址区分
+type resultCache struct {
+	sync.Mutex
+	entries map[string]*cacheEntry
+}
+
+func newResultCache() *resultCache {
+	return &resultCache{entries: make(map[string]*cacheEntry)}
+}
+
+// requestKey 以键、按名称排序的参数和附加参数生成请求的唯一标识，参数中包含连接地址
+func requestKey(key string, params map[string]string, extraParams []string) string {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	var b strings.Builder
+
+	b.WriteString(key)
+
+	for _, name := range names {
+		b.WriteString("\x00" + name + "=" + params[name])
+	}
+
+	for _, p := range extraParams {
+		b.WriteString("\x00" + p)
+	}
+
+	return b.String()
+}
+
+// get 返回不早于maxAge的缓存结果，并记录该监控项仍在被请求
+func (c *resultCache) get(id string, maxAge time.Duration) (interface{}, bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	e, ok := c.entries[id]
+	if !ok {
+		return nil, false
+	}
+
+	e.requested = time.Now()
+
+	if time.Since(e.updated) > maxAge {
+		return nil, false
+	}
+
+	return e.result, true
+}
+
+// set 保存实时查询得到的结果
+func (c *resultCache) set(id, key string, params map[string]string, extraParams []string, result interface{}) {
+	c.Lock()
+	defer c.Unlock()
+
+	now := time.Now()
+
+	c.entries[id] = &cacheEntry{
+		key:         key,
+		params:      params,
+		extraParams: extraParams,
+		result:      result,
+		updated:     now,
+		requested:   now,
+	}
+}
+
+// update 保存后台采集得到的结果，条目已被移除时忽略
+func (c *resultCache) update(id string, result interface{}) {
+	c.Lock()
+	defer c.Unlock()
+
+	if e, ok := c.entries[id]; ok {
+		e.result = result
+		e.updated = time.Now()
+	}
+}
+
+// active 返回在idle时间内被请求过的条目，并移除其余条目
+func (c *resultCache) active(idle time.Duration) map[string]cacheEntry {
+	c.Lock()
+	defer c.Unlock()
+
+	res := make(map[string]cacheEntry, len(c.entries))
+
+	for id, e := range c.entries {
+		if time.Since(e.requested) > idle {
+			delete(c.entries, id)
+
+			continue
+		}
+
+		res[id] = *e
+	}
+
+	return res
+}
+
+// Collect 实现Collector接口，按CollectInterval重新执行最近被请求过的可缓存监控项
+// 以可加载插件运行时agent不调度Collector，由连接管理器的sampler执行同样的采集
+func (p *Plugin) Collect() error {
+	if p.connMgr == nil || p.options.CollectInterval == 0 {
+		return nil
+	}
+
+	p.connMgr.collect()
+
+	return nil
+}
+
+// Period 实现Collector接口，未开启后台采集时仍返回默认周期，Collect不做任何操作
+func (p *Plugin) Period() int {
+	if p.options.CollectInterval == 0 {
+		return defaultCollectInterval
+	}
+
+	return p.options.CollectInterval
+}
+
+// sampler 按collectInterval重新执行最近被请求过的可缓存监控项，并移除超过keepAlive未被请求的条目
+// 每个键、参数和连接地址的组合每个周期只查询一次，Export在MaxStaleness内直接返回缓存结果
+func (c *ConnManager) sampler(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.collect()
+		}
+	}
+}
+
+// collect 重新执行缓存中仍在被请求的监控项
+// 本周期内已更新的条目跳过，agent调度的Collect和sampler同时运行时不会重复查询
+func (c *ConnManager) collect() {
+	for id, e := range c.cache.active(c.keepAlive) {
+		if time.Since(e.updated) < c.collectInterval/2 {
+			continue
+		}
+
+		conn, err := c.GetConnection(e.params)
+		if err != nil {
+			handlers.Logger.Debugf("cannot collect %s: %s", e.key, err)
+
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), conn.getTimeout())
+
+		release, err := c.acquire(ctx, conn.addr)
+		if err != nil {
+			cancel()
+			handlers.Logger.Debugf("cannot collect %s: %s", e.key, err)
+
+			continue
+		}
+
+		result, err := getHandlerFunc(e.key)(ctx, conn, e.params, e.extraParams...)
+		release()
+		cancel()
+
+		if err != nil {
+			handlers.Logger.Debugf("cannot collect %s: %s", e.key, err)
+
+			if _, evict := classifyError(err); evict {
+				c.evict(conn.addr)
+			}
+
+			continue
+		}
+
+		c.cache.update(id, result)
+	}
+}
+
+// cacheable 返回给定键的结果是否可由后台采集提供
+func (p *Plugin) cacheable(key string) bool {
+	return p.options.CollectInterval > 0 && collectedMetrics[key]
+}
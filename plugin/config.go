@@ -21,12 +21,17 @@ import (
 	"fmt"
 	"golang.zabbix.com/sdk/conf"
 	"golang.zabbix.com/sdk/plugin"
+	"strconv"
 )
 
 type Session struct {
 	URI        string `conf:"name=Uri"`                                // 连接字符串
 	MinIdle    string `conf:"name=MinIdle,range=1:100,default=5"`      // 最小空闲连接数
 	MaxConnect string `conf:"name=MaxConnect,range=1:200,default=100"` // 最大连接数
+
+	// MaxConcurrentQueries 同时执行的最大查询数，0表示不限制
+	// 超出的请求排队等待到监控项超时，队列长度为该值的4倍，队列已满时立即返回too many concurrent queries
+	MaxConcurrentQueries string `conf:"name=MaxConcurrentQueries,range=0:100,default=0"`
 }
 
 type PluginOptions struct {
@@ -37,12 +42,39 @@ type PluginOptions struct {
 	// KeepAlive  未使用连接关闭前的等待时间
 	KeepAlive int `conf:"optional,range=60:900,default=60"`
 
+	// CustomQueriesPath 自定义查询目录，其中每个*.sql文件对应oracle.custom.query的一个查询名
+	CustomQueriesPath string `conf:"optional"`
+
+	// CollectInterval 后台采集可缓存监控项的周期（秒），0表示不开启后台采集
+	CollectInterval int `conf:"optional,range=0:3600,default=0"`
+
+	// MaxStaleness 开启后台采集时，缓存结果可直接返回的最长时间（秒），超过后实时查询，不能小于CollectInterval
+	MaxStaleness int `conf:"optional,range=1:3600,default=120"`
+
 	// 存储预定义的命名连接设置集合
 	// 每个连接都有一个唯一的名称，用于在插件配置中引用
 	Sessions map[string]Session `conf:"optional"`
 	Default  Session            `conf:"optional"`
 }
 
+// queryLimits 返回各会话URI的MaxConcurrentQueries，同一URI配置在多个会话中时取最小值
+// 未在会话中配置的URI使用Default会话的设置
+func (o *PluginOptions) queryLimits() (limits map[string]int, def int) {
+	limits = make(map[string]int)
+
+	for _, session := range o.Sessions {
+		limit, _ := strconv.Atoi(session.MaxConcurrentQueries)
+
+		if cur, ok := limits[session.URI]; !ok || (limit > 0 && (cur == 0 || limit < cur)) {
+			limits[session.URI] = limit
+		}
+	}
+
+	def, _ = strconv.Atoi(o.Default.MaxConcurrentQueries)
+
+	return limits, def
+}
+
 // Configure 实现配置接口
 // 初始化配置结构
 func (p *Plugin) Configure(global *plugin.GlobalOptions, options interface{}) {
@@ -69,5 +101,11 @@ func (p *Plugin) Validate(options interface{}) error {
 		fmt.Println("options.Sessions: ", s, session.URI, session.MinIdle, session.MaxConnect)
 	}
 
+	// 缓存结果的有效期短于采集周期时，每个可缓存监控项在两次采集之间都会再实时查询一次
+	if opts.CollectInterval > 0 && opts.MaxStaleness < opts.CollectInterval {
+		return fmt.Errorf("MaxStaleness (%d) must not be less than CollectInterval (%d)",
+			opts.MaxStaleness, opts.CollectInterval)
+	}
+
 	return nil
 }
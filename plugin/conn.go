@@ -20,19 +20,23 @@ package plugin
 import (
 	"context"
 	"database/sql"
-	"fmt"
 	"github.com/892294101/zabbix-agent2-oracle/plugin/handlers"
 	_ "github.com/godror/godror"
-	"golang.zabbix.com/sdk/uri"
+	"golang.zabbix.com/sdk/zbxerr"
 	"sync"
 	"time"
 )
 
+const serverVersionQuery = `SELECT version FROM v$instance`
+
 type OracleConn struct {
+	sync.Mutex
 	addr           string
 	timeout        time.Duration
 	lastTimeAccess time.Time
 	session        *sql.DB
+	state          *handlers.State
+	version        string
 }
 
 func (conn *OracleConn) Database(name string) handlers.Session {
@@ -40,30 +44,288 @@ func (conn *OracleConn) Database(name string) handlers.Session {
 	panic("implement me")
 }
 
+// Ping 检查数据库是否可用，不刷新连接的最后访问时间，只被ping的连接仍会在keepAlive后关闭
 func (conn *OracleConn) Ping(ctx context.Context) error {
-	//TODO implement me
-	panic("implement me")
+	return conn.session.PingContext(ctx)
+}
+
+// Query 执行返回多行结果的查询，并刷新连接的最后访问时间
+func (conn *OracleConn) Query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	conn.updateAccessTime()
+
+	return conn.session.QueryContext(ctx, query, args...)
+}
+
+// QueryRow 执行最多返回一行结果的查询，并刷新连接的最后访问时间
+func (conn *OracleConn) QueryRow(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	conn.updateAccessTime()
+
+	return conn.session.QueryRowContext(ctx, query, args...)
+}
+
+// Version 返回首次连接时检测到的服务器版本，检测失败时为空
+func (conn *OracleConn) Version() string {
+	return conn.version
+}
+
+// State 返回该连接地址在多次轮询之间保留的状态
+func (conn *OracleConn) State() *handlers.State {
+	return conn.state
+}
+
+// updateAccessTime 更新连接的最后访问时间
+func (conn *OracleConn) updateAccessTime() {
+	conn.Lock()
+	defer conn.Unlock()
+
+	conn.lastTimeAccess = time.Now()
+}
+
+// getLastTimeAccess 返回连接的最后访问时间
+func (conn *OracleConn) getLastTimeAccess() time.Time {
+	conn.Lock()
+	defer conn.Unlock()
+
+	return conn.lastTimeAccess
 }
 
 type ConnManager struct {
 	sync.Mutex
 	connMutex   sync.Mutex
-	connections map[uri.URI]*OracleConn
+	connections map[string]*OracleConn
+	states      map[string]*handlers.State
 	keepAlive   time.Duration
 	timeout     time.Duration
+	queries     *handlers.QueryStorage
+	cache       *resultCache
+	Destroy     context.CancelFunc
+
+	// collectInterval 后台采集周期，为0时不采集
+	collectInterval time.Duration
+
+	// queryLimits 各连接地址的最大并发查询数，limiters中的限制器在连接重建后继续使用
+	queryLimits       map[string]int
+	defaultQueryLimit int
+	limiters          map[string]*queryLimiter
+
+	// breakers 各连接地址的断路器，由connMutex保护
+	breakers map[string]*circuitBreaker
+
+	// connects 合并到同一地址的并发连接尝试，建立连接期间不持有connMutex
+	connects callGroup
+
+	// stats 各连接地址的连接和查询计数，由Mutex保护
+	stats map[string]*addrStats
 }
 
 func (conn *OracleConn) getTimeout() time.Duration {
 	return conn.timeout
 }
 
-// NewConnManager 初始化connManager结构并运行Go例程，该例程监视未使用的连接。
-func NewConnManager(keepAlive, timeout, hkInterval time.Duration) *ConnManager {
-	connMgr := &ConnManager{connections: make(map[uri.URI]*OracleConn), keepAlive: keepAlive, timeout: timeout}
+// NewConnManager 初始化connManager结构并运行Go例程，该例程监视未使用的连接并重新加载自定义查询。
+// collectInterval大于0时另外运行后台采集可缓存监控项的Go例程。
+func NewConnManager(
+	keepAlive, timeout, hkInterval, collectInterval time.Duration, queries *handlers.QueryStorage,
+	queryLimits map[string]int, defaultQueryLimit int,
+) *ConnManager {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	connMgr := &ConnManager{
+		connections: make(map[string]*OracleConn),
+		states:      make(map[string]*handlers.State),
+		keepAlive:   keepAlive,
+		timeout:     timeout,
+		queries:     queries,
+		cache:       newResultCache(),
+		Destroy:     cancel,
+
+		collectInterval: collectInterval,
+
+		queryLimits:       queryLimits,
+		defaultQueryLimit: defaultQueryLimit,
+		limiters:          make(map[string]*queryLimiter),
+
+		breakers: make(map[string]*circuitBreaker),
+		stats:    make(map[string]*addrStats),
+	}
+
+	go connMgr.housekeeper(ctx, hkInterval)
+
+	if collectInterval > 0 {
+		go connMgr.sampler(ctx, collectInterval)
+	}
+
 	return connMgr
 }
 
+// housekeeper 定期关闭超过keepAlive未使用的连接并重新加载自定义查询，插件停止时关闭全部连接
+func (c *ConnManager) housekeeper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			c.closeAll()
+
+			return
+		case <-ticker.C:
+			c.closeUnused()
+			c.reloadQueries()
+		}
+	}
+}
+
+// closeUnused 关闭超过keepAlive未使用的连接
+func (c *ConnManager) closeUnused() {
+	c.connMutex.Lock()
+	defer c.connMutex.Unlock()
+
+	for addr, conn := range c.connections {
+		if time.Since(conn.getLastTimeAccess()) > c.keepAlive {
+			conn.session.Close()
+			delete(c.connections, addr)
+		}
+	}
+}
+
+// reloadQueries 重新加载自定义查询目录中新增、修改和删除的文件
+func (c *ConnManager) reloadQueries() {
+	if c.queries == nil {
+		return
+	}
+
+	if err := c.queries.Reload(); err != nil {
+		handlers.Logger.Errf("cannot reload custom queries: %s", err)
+	}
+}
+
+// evict 关闭并移除给定地址的连接，下次请求时重新建立
+func (c *ConnManager) evict(addr string) {
+	c.connMutex.Lock()
+	defer c.connMutex.Unlock()
+
+	if conn, ok := c.connections[addr]; ok {
+		conn.session.Close()
+		delete(c.connections, addr)
+	}
+}
+
+// closeAll 关闭全部连接
+func (c *ConnManager) closeAll() {
+	c.connMutex.Lock()
+	defer c.connMutex.Unlock()
+
+	for addr, conn := range c.connections {
+		conn.session.Close()
+		delete(c.connections, addr)
+	}
+}
+
+// create 打开到给定地址的新连接，并在放入连接池前检查其可用性
+// 连接和检查可能持续到timeout，调用时不得持有connMutex
+func (c *ConnManager) create(addr string, state *handlers.State) (*OracleConn, error) {
+	db, err := sql.Open("godror", addr)
+	if err != nil {
+		return nil, zbxerr.ErrorConnectionFailed.Wrap(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	if err = db.PingContext(ctx); err != nil {
+		db.Close()
+
+		return nil, zbxerr.ErrorConnectionFailed.Wrap(err)
+	}
+
+	var version string
+
+	if err = db.QueryRowContext(ctx, serverVersionQuery).Scan(&version); err != nil {
+		handlers.Logger.Warningf("cannot detect server version of %s: %s", addr, err)
+	}
+
+	return &OracleConn{
+		addr:           addr,
+		timeout:        c.timeout,
+		lastTimeAccess: time.Now(),
+		session:        db,
+		state:          state,
+		version:        version,
+	}, nil
+}
+
+// GetConnection 返回给定URI的已有连接，若不存在则创建新连接
+// 连接的最后访问时间只由查询刷新
+// 该地址的断路器打开或正在由其他请求探测时不尝试连接，直接返回错误
+// 其余并发请求等待同一次连接尝试的结果，建立连接期间其他地址的请求不受影响
 func (c *ConnManager) GetConnection(params map[string]string) (*OracleConn, error) {
-	fmt.Println("GetConnection: ", params)
-	return nil, nil
+	addr := params["URI"]
+
+	c.connMutex.Lock()
+
+	if conn, ok := c.connections[addr]; ok {
+		c.connMutex.Unlock()
+
+		return conn, nil
+	}
+
+	b, ok := c.breakers[addr]
+	if !ok {
+		b = &circuitBreaker{}
+		c.breakers[addr] = b
+	}
+
+	err := b.allow(time.Now())
+	c.connMutex.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err, _ := c.connects.do(addr, func() (interface{}, error) {
+		return c.connect(addr, b)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return conn.(*OracleConn), nil
+}
+
+// connect 建立到给定地址的连接并放入连接池，同时记录断路器和统计信息
+func (c *ConnManager) connect(addr string, b *circuitBreaker) (*OracleConn, error) {
+	c.connMutex.Lock()
+
+	// 等待期间其他请求可能已经建立了连接，半开状态下的探测也随之结束
+	if conn, ok := c.connections[addr]; ok {
+		b.done(true, time.Now())
+		c.connMutex.Unlock()
+
+		return conn, nil
+	}
+
+	state, ok := c.states[addr]
+	if !ok {
+		state = handlers.NewState()
+		c.states[addr] = state
+	}
+
+	c.connMutex.Unlock()
+
+	conn, err := c.create(addr, state)
+
+	c.connMutex.Lock()
+	defer c.connMutex.Unlock()
+
+	b.done(err == nil, time.Now())
+	c.recordConnect(addr, err)
+
+	if err != nil {
+		return nil, err
+	}
+
+	c.connections[addr] = conn
+
+	return conn, nil
 }
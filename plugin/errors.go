@@ -0,0 +1,147 @@
+/*
+** Zabbix
+** Copyright 2001-2022 Zabbix SIA
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+**     http://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+**/
+
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/godror/godror"
+	"golang.zabbix.com/sdk/zbxerr"
+	"strconv"
+)
+
+// 错误分类，每类对应一个固定的监控项错误信息
+var (
+	errAuthFailed            = zbxerr.New("authentication failed")
+	errInsufficientPrivilege = zbxerr.New("insufficient privileges")
+	errNetwork               = zbxerr.New("network error")
+	errTimeout               = zbxerr.New("operation timed out")
+	errResourceExhausted     = zbxerr.New("database resources exhausted")
+)
+
+type errorClass struct {
+	err zbxerr.ZabbixError
+	// evict 为true时连接本身已不可用，需要从连接池移除
+	evict bool
+}
+
+var (
+	classAuth      = errorClass{err: errAuthFailed, evict: true}
+	classPrivilege = errorClass{err: errInsufficientPrivilege}
+	classNetwork   = errorClass{err: errNetwork, evict: true}
+	classTimeout   = errorClass{err: errTimeout}
+	classResource  = errorClass{err: errResourceExhausted}
+)
+
+var oraErrorClasses = map[int]errorClass{
+	1017:  classAuth,      // invalid username/password
+	28000: classAuth,      // account is locked
+	942:   classPrivilege, // table or view does not exist
+	1031:  classPrivilege, // insufficient privileges
+	3113:  classNetwork,   // end-of-file on communication channel
+	3114:  classNetwork,   // not connected to ORACLE
+	3135:  classNetwork,   // connection lost contact
+	12514: classNetwork,   // listener does not currently know of service
+	12541: classNetwork,   // no listener
+	12543: classNetwork,   // destination host unreachable
+	1013:  classTimeout,   // user requested cancel of current operation
+	12170: classTimeout,   // connect timeout occurred
+	18:    classResource,  // maximum number of sessions exceeded
+	20:    classResource,  // maximum number of processes exceeded
+	4031:  classResource,  // unable to allocate shared memory
+}
+
+// causes 返回错误本身以及zbxerr包装链中依次保存在Cause中的原始错误
+// zbxerr的Unwrap返回错误分类而不是原始错误，errors.Is和errors.As无法沿Cause查找
+func causes(err error) []error {
+	var res []error
+
+	for err != nil {
+		res = append(res, err)
+
+		c, ok := err.(interface{ Cause() error })
+		if !ok {
+			break
+		}
+
+		err = c.Cause()
+	}
+
+	return res
+}
+
+// oraError 查找错误链中的ORA错误，zbxerr包装的原始错误保存在Cause中
+func oraError(err error) (*godror.OraErr, bool) {
+	for _, e := range causes(err) {
+		if oe, ok := godror.AsOraErr(e); ok {
+			return oe, true
+		}
+	}
+
+	return nil, false
+}
+
+// oraCode 返回错误中的ORA代码，不是ORA错误时返回空字符串
+func oraCode(err error) string {
+	if oe, ok := oraError(err); ok && oe.Code() != 0 {
+		return fmt.Sprintf("ORA-%05d", oe.Code())
+	}
+
+	return oraCodeRegex.FindString(err.Error())
+}
+
+// classifyError 将驱动错误转换为对应分类的监控项错误，返回是否需要移除连接
+// 驱动错误被转换为文本时按其中的ORA代码分类，无法分类的错误原样返回
+func classifyError(err error) (error, bool) {
+	chain := causes(err)
+
+	for _, e := range chain {
+		if errors.Is(e, context.DeadlineExceeded) {
+			return errTimeout.Wrap(e), false
+		}
+	}
+
+	if oe, ok := oraError(err); ok {
+		class, ok := oraErrorClasses[oe.Code()]
+		if !ok {
+			return err, false
+		}
+
+		return class.err.Wrap(fmt.Errorf("ORA-%05d: %s", oe.Code(), oe.Message())), class.evict
+	}
+
+	// 从最内层的原始错误开始查找，分类后的错误信息不重复外层的说明
+	for i := len(chain) - 1; i >= 0; i-- {
+		code := oraCodeRegex.FindString(chain[i].Error())
+		if code == "" {
+			continue
+		}
+
+		n, _ := strconv.Atoi(code[len("ORA-"):])
+
+		class, ok := oraErrorClasses[n]
+		if !ok {
+			return err, false
+		}
+
+		return class.err.Wrap(chain[i]), class.evict
+	}
+
+	return err, false
+}
@@ -0,0 +1,118 @@
+/*
+** Zabbix
+** Copyright 2001-2022 Zabbix SIA
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+**     http://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+**/
+
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	pszbxerr "git.zabbix.com/ap/plugin-support/zbxerr"
+	"golang.zabbix.com/sdk/zbxerr"
+	"testing"
+)
+
+func TestClassifyError(t *testing.T) {
+	auth := errors.New("ORA-01017: invalid username/password; logon denied")
+
+	tests := []struct {
+		name      string
+		err       error
+		want      error
+		wantEvict bool
+	}{
+		{"deadline", context.DeadlineExceeded, errTimeout, false},
+		{"handler wrapped deadline", pszbxerr.ErrorCannotFetchData.Wrap(context.DeadlineExceeded), errTimeout, false},
+		{"deadline in fmt chain", zbxerr.ErrorCannotFetchData.Wrap(fmt.Errorf("query: %w", context.DeadlineExceeded)),
+			errTimeout, false},
+		{"nested zbxerr deadline",
+			zbxerr.ErrorConnectionFailed.Wrap(pszbxerr.ErrorCannotFetchData.Wrap(context.DeadlineExceeded)),
+			errTimeout, false},
+		{"invalid password", auth, errAuthFailed, true},
+		{"handler wrapped password", pszbxerr.ErrorCannotFetchData.Wrap(auth), errAuthFailed, true},
+		{"locked account", zbxerr.ErrorConnectionFailed.Wrap(errors.New("ORA-28000: the account is locked")),
+			errAuthFailed, true},
+		{"missing view", pszbxerr.ErrorCannotFetchData.Wrap(errors.New("ORA-00942: table or view does not exist")),
+			errInsufficientPrivilege, false},
+		{"insufficient privileges", errors.New("ORA-01031: insufficient privileges"), errInsufficientPrivilege, false},
+		{"end of file", pszbxerr.ErrorCannotFetchData.Wrap(errors.New("ORA-03113: end-of-file on communication channel")),
+			errNetwork, true},
+		{"no listener", zbxerr.ErrorConnectionFailed.Wrap(errors.New("ORA-12541: TNS:no listener")), errNetwork, true},
+		{"cancelled call", pszbxerr.ErrorCannotFetchData.Wrap(errors.New("ORA-01013: user requested cancel")),
+			errTimeout, false},
+		{"connect timeout", errors.New("ORA-12170: TNS:Connect timeout occurred"), errTimeout, false},
+		{"sessions exceeded", errors.New("ORA-00018: maximum number of sessions exceeded"), errResourceExhausted, false},
+		{"shared pool", pszbxerr.ErrorCannotFetchData.Wrap(errors.New("ORA-04031: unable to allocate 4096 bytes")),
+			errResourceExhausted, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, evict := classifyError(tt.err)
+			if !errors.Is(got, tt.want) {
+				t.Errorf("classifyError() = %v, want %v", got, tt.want)
+			}
+
+			if evict != tt.wantEvict {
+				t.Errorf("classifyError() evict = %v, want %v", evict, tt.wantEvict)
+			}
+		})
+	}
+}
+
+func TestClassifyErrorUnknown(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{"plain", errors.New("no rows")},
+		{"cancelled context", pszbxerr.ErrorCannotFetchData.Wrap(context.Canceled)},
+		{"unlisted ORA code", pszbxerr.ErrorCannotFetchData.Wrap(errors.New("ORA-01555: snapshot too old"))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, evict := classifyError(tt.err)
+			if got != tt.err || evict {
+				t.Errorf("classifyError() = %v, %v, want the original error", got, evict)
+			}
+		})
+	}
+}
+
+func TestOraCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"plain", errors.New("ORA-00942: table or view does not exist"), "ORA-00942"},
+		{"handler wrapped", pszbxerr.ErrorCannotFetchData.Wrap(errors.New("ORA-01013: user requested cancel")),
+			"ORA-01013"},
+		{"plugin wrapped", zbxerr.ErrorConnectionFailed.Wrap(errors.New("dial: ORA-12541: TNS:no listener")),
+			"ORA-12541"},
+		{"not an ORA error", pszbxerr.ErrorCannotFetchData.Wrap(context.DeadlineExceeded), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := oraCode(tt.err); got != tt.want {
+				t.Errorf("oraCode() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
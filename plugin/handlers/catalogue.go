@@ -0,0 +1,296 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"git.zabbix.com/ap/plugin-support/zbxerr"
+)
+
+// 目录指标的结果形式
+const (
+	ResultValue  = "value"  // 第一行第一列的值
+	ResultRow    = "row"    // 第一行，以列名为键的对象
+	ResultLLD    = "lld"    // 低级发现列表，列名转换为{#COLUMN}宏
+	ResultObject = "object" // 以第一列的值为键、其余列为值的对象
+	ResultRows   = "rows"   // 全部行的数组，指定Collection时返回{"count": N, "<Collection>": [...]}
+)
+
+// CatalogueParam 描述目录指标的一个键参数，参数值按声明顺序绑定为:1、:2…
+type CatalogueParam struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Default     string   `json:"default"`
+	Required    bool     `json:"required"`
+	Values      []string `json:"values"`
+	Min         *int     `json:"min"`
+	Max         *int     `json:"max"`
+}
+
+// CatalogueVariant 从给定版本起适用的查询写法
+type CatalogueVariant struct {
+	Since string `json:"since"`
+	Query string `json:"query"`
+}
+
+// CatalogueMetric 描述一个由通用处理程序执行的指标
+// 查询由Query和MinVersion给出，不同版本写法不同时改用按版本从低到高排列的Variants
+// Delta中的列以相邻两次轮询之间的每秒变化量返回，首次轮询和计数器重置时为null
+// NullAsEmpty中的列为NULL时返回空字符串，Oracle将空字符串视为NULL，无法在查询中用NVL实现
+// Collect为true时结果可由后台采集提供
+type CatalogueMetric struct {
+	Key         string             `json:"key"`
+	Description string             `json:"description"`
+	Query       string             `json:"query"`
+	MinVersion  string             `json:"min_version"`
+	Variants    []CatalogueVariant `json:"variants"`
+	Params      []CatalogueParam   `json:"params"`
+	Result      string             `json:"result"`
+	Collection  string             `json:"collection"`
+	Delta       []string           `json:"delta"`
+	NullAsEmpty []string           `json:"null_as_empty"`
+	Collect     bool               `json:"collect"`
+
+	query *VersionedQuery
+}
+
+type catalogueSample struct {
+	ts     time.Time
+	values map[string]map[string]float64
+}
+
+// Validate 检查目录指标定义是否完整，并按版本整理查询
+func (m *CatalogueMetric) Validate() error {
+	if m.Key == "" || (m.Query == "") == (len(m.Variants) == 0) {
+		return fmt.Errorf("catalogue metric %q: key and either query or variants are required", m.Key)
+	}
+
+	m.query = NewVersionedQuery(m.Key)
+
+	if m.Query != "" {
+		m.query.Since(m.MinVersion, m.Query)
+	}
+
+	for _, v := range m.Variants {
+		if v.Query == "" {
+			return fmt.Errorf("catalogue metric %q: variant query is required", m.Key)
+		}
+
+		m.query.Since(v.Since, v.Query)
+	}
+
+	switch m.Result {
+	case ResultValue, ResultRow, ResultObject:
+	case ResultLLD, ResultRows:
+		if len(m.Delta) > 0 {
+			return fmt.Errorf("catalogue metric %q: delta columns are not supported for %s result", m.Key, m.Result)
+		}
+	default:
+		return fmt.Errorf("catalogue metric %q: unknown result %q", m.Key, m.Result)
+	}
+
+	for _, p := range m.Params {
+		if p.Name == "" {
+			return fmt.Errorf("catalogue metric %q: parameter name is required", m.Key)
+		}
+	}
+
+	return nil
+}
+
+// Handler 执行目录指标的查询并按Result整理结果
+func (m *CatalogueMetric) Handler(ctx context.Context, s Database, params map[string]string,
+	_ ...string) (interface{}, error) {
+	query, err := m.query.For(s.Version())
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]interface{}, len(m.Params))
+	values := make([]string, len(m.Params))
+
+	for i, p := range m.Params {
+		args[i] = params[p.Name]
+		values[i] = params[p.Name]
+	}
+
+	rows, err := s.Query(ctx, query, args...)
+	if err != nil {
+		return nil, zbxerr.ErrorCannotFetchData.Wrap(err)
+	}
+	defer rows.Close()
+
+	columns, data, err := fetchRows(rows)
+	if err != nil {
+		return nil, zbxerr.ErrorCannotFetchData.Wrap(err)
+	}
+
+	m.emptyNulls(data)
+
+	if len(m.Delta) > 0 {
+		stateKey := "catalogue|" + m.Key + "|" + strings.Join(values, "|")
+
+		err = s.State().Do(stateKey, func(prev interface{}) (interface{}, error) {
+			last, _ := prev.(catalogueSample)
+
+			return m.applyDelta(columns, data, last, time.Now()), nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return m.format(columns, data)
+}
+
+// emptyNulls 将NullAsEmpty列中的NULL替换为空字符串
+func (m *CatalogueMetric) emptyNulls(data []map[string]interface{}) {
+	for _, row := range data {
+		for _, col := range m.NullAsEmpty {
+			if v, ok := row[col]; ok && v == nil {
+				row[col] = ""
+			}
+		}
+	}
+}
+
+// applyDelta 以每秒变化量替换Delta列的值，返回本次采样供下次轮询使用
+func (m *CatalogueMetric) applyDelta(columns []string, data []map[string]interface{}, last catalogueSample,
+	now time.Time) catalogueSample {
+	cur := catalogueSample{ts: now, values: make(map[string]map[string]float64, len(data))}
+	elapsed := now.Sub(last.ts).Seconds()
+
+	for _, row := range data {
+		key := m.rowKey(columns, row)
+		values := make(map[string]float64, len(m.Delta))
+
+		for _, col := range m.Delta {
+			v, ok := toFloat64(row[col])
+			if !ok {
+				row[col] = nil
+
+				continue
+			}
+
+			values[col] = v
+			row[col] = nil
+
+			prev, ok := last.values[key][col]
+			if ok && v >= prev && elapsed > 0 {
+				row[col] = (v - prev) / elapsed
+			}
+		}
+
+		cur.values[key] = values
+	}
+
+	return cur
+}
+
+// rowKey 对象结果以第一列区分各行，其他结果只使用第一行
+func (m *CatalogueMetric) rowKey(columns []string, row map[string]interface{}) string {
+	if m.Result != ResultObject || len(columns) == 0 {
+		return ""
+	}
+
+	return fmt.Sprint(row[columns[0]])
+}
+
+func (m *CatalogueMetric) format(columns []string, data []map[string]interface{}) (interface{}, error) {
+	var res interface{}
+
+	switch m.Result {
+	case ResultValue:
+		if len(data) == 0 || len(columns) == 0 {
+			return nil, zbxerr.ErrorEmptyResult
+		}
+
+		return scalarValue(data[0][columns[0]]), nil
+	case ResultRow:
+		if len(data) == 0 {
+			return nil, zbxerr.ErrorEmptyResult
+		}
+
+		res = data[0]
+	case ResultLLD:
+		lld := make([]map[string]string, 0, len(data))
+
+		for _, row := range data {
+			macros := make(map[string]string, len(row))
+
+			for col, v := range row {
+				macros["{#"+strings.ToUpper(col)+"}"] = macroValue(v)
+			}
+
+			lld = append(lld, macros)
+		}
+
+		res = lld
+	case ResultObject:
+		obj := make(map[string]map[string]interface{}, len(data))
+
+		for _, row := range data {
+			key := m.rowKey(columns, row)
+			delete(row, columns[0])
+			obj[key] = row
+		}
+
+		res = obj
+	case ResultRows:
+		res = data
+
+		if m.Collection != "" {
+			res = map[string]interface{}{"count": len(data), m.Collection: data}
+		}
+	}
+
+	jsonRes, err := json.Marshal(res)
+	if err != nil {
+		return nil, zbxerr.ErrorCannotMarshalJSON.Wrap(err)
+	}
+
+	return string(jsonRes), nil
+}
+
+// scalarValue 将驱动返回的数值类型转换为agent可直接处理的类型
+func scalarValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case nil, string, int64, float64:
+		return val
+	case time.Time:
+		return val.Unix()
+	default:
+		return fmt.Sprint(val)
+	}
+}
+
+// macroValue 低级发现宏的值统一为字符串，NULL对应空字符串
+func macroValue(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	default:
+		return fmt.Sprint(val)
+	}
+}
+
+func toFloat64(v interface{}) (float64, bool) {
+	switch val := v.(type) {
+	case nil:
+		return 0, false
+	case int64:
+		return float64(val), true
+	case float64:
+		return val, true
+	default:
+		f, err := strconv.ParseFloat(fmt.Sprint(val), 64)
+
+		return f, err == nil
+	}
+}
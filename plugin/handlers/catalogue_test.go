@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/godror/godror"
+)
+
+func TestCatalogueFormat(t *testing.T) {
+	longOps := &CatalogueMetric{
+		Key:         "oracle.longops",
+		Query:       "SELECT 1 FROM dual",
+		Result:      ResultRows,
+		Collection:  "operations",
+		NullAsEmpty: []string{"username", "sql_id"},
+	}
+
+	discovery := &CatalogueMetric{
+		Key:    "oracle.rac.instances.discovery",
+		Query:  "SELECT 1 FROM dual",
+		Result: ResultLLD,
+	}
+
+	tests := []struct {
+		name    string
+		m       *CatalogueMetric
+		columns []string
+		row     map[string]interface{}
+		want    string
+	}{
+		{
+			"rows keep numbers and empty strings",
+			longOps,
+			[]string{"sid", "username", "sql_id", "percent", "time_remaining"},
+			map[string]interface{}{
+				"sid":            godror.Number("17"),
+				"username":       nil,
+				"sql_id":         "8fz2d3x0a1b2c",
+				"percent":        godror.Number("33.33"),
+				"time_remaining": nil,
+			},
+			`{"count":1,"operations":[{"percent":33.33,"sid":17,"sql_id":"8fz2d3x0a1b2c","time_remaining":null,"username":""}]}`,
+		},
+		{
+			"lld macros are strings",
+			discovery,
+			[]string{"inst_id", "instance_name"},
+			map[string]interface{}{"inst_id": godror.Number("2"), "instance_name": "orcl2"},
+			`[{"{#INSTANCE_NAME}":"orcl2","{#INST_ID}":"2"}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.m.Validate(); err != nil {
+				t.Fatal(err)
+			}
+
+			row := make(map[string]interface{}, len(tt.row))
+			for col, v := range tt.row {
+				row[col] = columnValue(v)
+			}
+
+			data := []map[string]interface{}{row}
+			tt.m.emptyNulls(data)
+
+			got, err := tt.m.format(tt.columns, data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got != tt.want {
+				t.Errorf("format() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,293 @@
+package handlers
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const customQueryExt = ".sql"
+
+// CustomQueries 保存从CustomQueriesPath目录加载的自定义查询，未配置目录时为nil
+var CustomQueries *QueryStorage
+
+type customQuery struct {
+	text    string
+	modTime time.Time
+	size    int64
+}
+
+// QueryStorage 以去掉扩展名的文件名为查询名保存自定义SQL
+type QueryStorage struct {
+	sync.RWMutex
+	path    string
+	queries map[string]customQuery
+	loaded  bool
+}
+
+// NewQueryStorage 加载给定目录下的全部*.sql文件，无法读取的文件记录日志后跳过
+// 目录无法访问时记录日志并返回空的存储，之后每次Reload重试
+func NewQueryStorage(path string) *QueryStorage {
+	qs := &QueryStorage{
+		path:    path,
+		queries: make(map[string]customQuery),
+	}
+
+	if err := qs.Reload(); err != nil {
+		Logger.Errf("cannot load custom queries: %s", err)
+	}
+
+	return qs
+}
+
+// Get 返回给定名称的查询语句
+func (qs *QueryStorage) Get(name string) (string, bool) {
+	qs.RLock()
+	defer qs.RUnlock()
+
+	query, ok := qs.queries[name]
+
+	return query.text, ok
+}
+
+// Reload 重新扫描目录，加载新增和修改时间或大小发生变化的文件，并移除已删除文件对应的查询
+// 无法读取的文件记录日志后保留之前加载的版本，新文件在读取完成后一次性替换，不影响正在执行的查询
+func (qs *QueryStorage) Reload() error {
+	if _, err := os.Stat(qs.path); err != nil {
+		return err
+	}
+
+	files, err := filepath.Glob(filepath.Join(qs.path, "*"+customQueryExt))
+	if err != nil {
+		return err
+	}
+
+	qs.RLock()
+	prev := qs.queries
+	qs.RUnlock()
+
+	queries := make(map[string]customQuery, len(files))
+
+	for _, file := range files {
+		name := customQueryName(file)
+		old, exists := prev[name]
+
+		info, err := os.Stat(file)
+		if err != nil {
+			Logger.Errf("cannot load custom query: %s", err)
+
+			if exists {
+				queries[name] = old
+			}
+
+			continue
+		}
+
+		if exists && info.ModTime().Equal(old.modTime) && info.Size() == old.size {
+			queries[name] = old
+
+			continue
+		}
+
+		text, err := readCustomQuery(file)
+		if err != nil {
+			Logger.Errf("cannot load custom query: %s", err)
+
+			if exists {
+				queries[name] = old
+			}
+
+			continue
+		}
+
+		if exists {
+			Logger.Infof("custom query %s reloaded", name)
+		} else if qs.loaded {
+			Logger.Infof("custom query %s added", name)
+		}
+
+		queries[name] = customQuery{text: text, modTime: info.ModTime(), size: info.Size()}
+	}
+
+	for name := range prev {
+		if _, ok := queries[name]; !ok {
+			Logger.Infof("custom query %s removed", name)
+		}
+	}
+
+	qs.Lock()
+	qs.queries = queries
+	qs.Unlock()
+
+	qs.loaded = true
+
+	return nil
+}
+
+func customQueryName(file string) string {
+	return strings.TrimSuffix(filepath.Base(file), customQueryExt)
+}
+
+// readCustomQuery 读取查询文件，去掉SQL*Plus风格的结尾分号和斜杠，并检查查询能否作为监控项执行
+func readCustomQuery(file string) (string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+
+	query := strings.TrimSpace(string(data))
+	query = strings.TrimSuffix(query, "/")
+	query = strings.TrimSpace(query)
+	query = strings.TrimSuffix(query, ";")
+	query = strings.TrimSpace(query)
+
+	if query == "" {
+		return "", fmt.Errorf("custom query file %s is empty", file)
+	}
+
+	if err = validateCustomQuery(query); err != nil {
+		return "", fmt.Errorf("custom query file %s: %s", file, err)
+	}
+
+	return query, nil
+}
+
+// validateCustomQuery 检查查询是单条SELECT或WITH语句，且绑定变量能按键参数的顺序绑定
+// 数字形式的绑定变量须从:1开始连续编号，不能与命名形式混用
+func validateCustomQuery(query string) error {
+	tokens, binds, err := scanCustomQuery(query)
+	if err != nil {
+		return err
+	}
+
+	if len(tokens) == 0 {
+		return fmt.Errorf("query contains only comments")
+	}
+
+	if kw := strings.ToUpper(tokens[0]); kw != "SELECT" && kw != "WITH" {
+		return fmt.Errorf("only SELECT statements are allowed, got %s", kw)
+	}
+
+	numbered := make(map[int]bool)
+	named := false
+
+	for _, b := range binds {
+		n, err := strconv.Atoi(b)
+		if err != nil {
+			named = true
+
+			continue
+		}
+
+		numbered[n] = true
+	}
+
+	if named && len(numbered) > 0 {
+		return fmt.Errorf("numbered and named bind variables cannot be mixed")
+	}
+
+	for i := 1; i <= len(numbered); i++ {
+		if !numbered[i] {
+			return fmt.Errorf("bind variable :%d is missing, numbered bind variables must start at :1", i)
+		}
+	}
+
+	return nil
+}
+
+// scanCustomQuery 跳过注释和引号中的内容，返回语句中的单词和绑定变量名
+// 语句中间的分号说明文件包含多条语句
+func scanCustomQuery(query string) (tokens, binds []string, err error) {
+	for i := 0; i < len(query); {
+		c := query[i]
+
+		switch {
+		case strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				return tokens, binds, nil
+			}
+
+			i += end + 1
+		case strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				return nil, nil, fmt.Errorf("unterminated comment")
+			}
+
+			i += end + 4
+		case (c == 'q' || c == 'Q') && i+2 < len(query) && query[i+1] == '\'':
+			// q'[...]'形式的字符串，结束符为与起始分隔符配对的字符加单引号
+			closing := query[i+2]
+			if pair, ok := quotePairs[closing]; ok {
+				closing = pair
+			}
+
+			end := strings.Index(query[i+3:], string(closing)+"'")
+			if end < 0 {
+				return nil, nil, fmt.Errorf("unterminated string literal")
+			}
+
+			i += end + 5
+		case c == '\'' || c == '"':
+			end := i + 1
+
+			for ; end < len(query); end++ {
+				if query[end] != c {
+					continue
+				}
+
+				// 连续两个引号表示引号本身
+				if end+1 < len(query) && query[end+1] == c {
+					end++
+
+					continue
+				}
+
+				break
+			}
+
+			if end >= len(query) {
+				return nil, nil, fmt.Errorf("unterminated quoted string")
+			}
+
+			i = end + 1
+		case c == ';':
+			return nil, nil, fmt.Errorf("only a single statement is allowed")
+		case c == ':':
+			end := i + 1
+			for end < len(query) && isIdentChar(query[end]) {
+				end++
+			}
+
+			if end == i+1 {
+				return nil, nil, fmt.Errorf("invalid bind variable at offset %d", i)
+			}
+
+			binds = append(binds, strings.ToUpper(query[i+1:end]))
+			i = end
+		case isIdentChar(c):
+			end := i + 1
+			for end < len(query) && isIdentChar(query[end]) {
+				end++
+			}
+
+			tokens = append(tokens, query[i:end])
+			i = end
+		default:
+			i++
+		}
+	}
+
+	return tokens, binds, nil
+}
+
+var quotePairs = map[byte]byte{'[': ']', '{': '}', '(': ')', '<': '>'}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c == '$' || c == '#' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testLogger 将插件日志写入测试输出
+type testLogger struct {
+	t *testing.T
+}
+
+func (l testLogger) Infof(format string, args ...interface{})    { l.t.Logf(format, args...) }
+func (l testLogger) Critf(format string, args ...interface{})    { l.t.Logf(format, args...) }
+func (l testLogger) Errf(format string, args ...interface{})     { l.t.Logf(format, args...) }
+func (l testLogger) Warningf(format string, args ...interface{}) { l.t.Logf(format, args...) }
+func (l testLogger) Debugf(format string, args ...interface{})   { l.t.Logf(format, args...) }
+func (l testLogger) Tracef(format string, args ...interface{})   { l.t.Logf(format, args...) }
+
+func TestValidateCustomQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		wantErr bool
+	}{
+		{"select", "SELECT COUNT(*) FROM orders WHERE status = :1", false},
+		{"with", "with q AS (SELECT 1 FROM dual) SELECT * FROM q", false},
+		{"leading comment", "-- queue depth\n/* owner: app */ SELECT 1 FROM dual", false},
+		{"two binds", "SELECT 1 FROM dual WHERE :1 < :2 AND :1 > 0", false},
+		{"named binds", "SELECT 1 FROM dual WHERE x = :owner AND y = :name", false},
+		{"colon in literal", "SELECT TO_CHAR(SYSDATE, 'HH24:MI') FROM dual", false},
+		{"semicolon in literal", "SELECT 'a;b', q'[it's :1;]' FROM dual", false},
+		{"quoted identifier", `SELECT "a:b" FROM dual`, false},
+		{"update", "UPDATE orders SET status = 'X'", true},
+		{"plsql", "BEGIN NULL; END", true},
+		{"only comment", "-- nothing here", true},
+		{"two statements", "SELECT 1 FROM dual; SELECT 2 FROM dual", true},
+		{"bind gap", "SELECT 1 FROM dual WHERE x = :2", true},
+		{"mixed binds", "SELECT 1 FROM dual WHERE x = :1 AND y = :name", true},
+		{"empty bind", "SELECT 1 FROM dual WHERE x = : 1", true},
+		{"unterminated literal", "SELECT 'abc FROM dual", true},
+		{"unterminated comment", "SELECT 1 /* FROM dual", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateCustomQuery(tt.query)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateCustomQuery(%q) error = %v, wantErr %v", tt.query, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestQueryStorageKeepsPreviousVersion(t *testing.T) {
+	Logger = testLogger{t}
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "orders.sql")
+
+	if err := os.WriteFile(file, []byte("SELECT COUNT(*) FROM orders;\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	qs := NewQueryStorage(dir)
+
+	if query, ok := qs.Get("orders"); !ok || query != "SELECT COUNT(*) FROM orders" {
+		t.Fatalf("Get() = %q, %v, want loaded query", query, ok)
+	}
+
+	if err := os.WriteFile(file, []byte("DELETE FROM orders WHERE id = :2"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := qs.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	if query, ok := qs.Get("orders"); !ok || query != "SELECT COUNT(*) FROM orders" {
+		t.Errorf("Get() after invalid change = %q, %v, want previous version", query, ok)
+	}
+
+	if err := os.WriteFile(file, []byte("SELECT COUNT(*) FROM orders WHERE status = :1"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := qs.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	if query, _ := qs.Get("orders"); query != "SELECT COUNT(*) FROM orders WHERE status = :1" {
+		t.Errorf("Get() after valid change = %q, want new version", query)
+	}
+}
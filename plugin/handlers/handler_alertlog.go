@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"time"
+
+	"git.zabbix.com/ap/plugin-support/zbxerr"
+)
+
+const alertLogStateKey = "alertlog.hwm"
+
+// 只读取当前实例rdbms组件的告警日志，包含ORA-错误或级别为critical/severe的消息
+// v$diag_alert_ext从12.1起提供
+var alertLogQuery = NewVersionedQuery("v$diag_alert_ext").Since("12.1", `
+SELECT originating_timestamp,
+       record_id,
+       ROUND((CAST(SYS_EXTRACT_UTC(originating_timestamp) AS DATE) - DATE '1970-01-01') * 86400),
+       message_level,
+       message_type,
+       message_text
+  FROM v$diag_alert_ext
+ WHERE component_id = 'rdbms'
+   AND originating_timestamp >= :1
+   AND (message_text LIKE '%ORA-%' OR message_level <= 2)
+ ORDER BY originating_timestamp, record_id`)
+
+const alertLogBaselineQuery = `SELECT SYSTIMESTAMP FROM dual`
+
+var oraCodeRegex = regexp.MustCompile(`ORA-\d{5}`)
+
+// alertLogMark 告警日志高水位线，已上报记录的最大时间戳和同一时间戳下的最大record_id
+type alertLogMark struct {
+	ts       time.Time
+	recordID int64
+}
+
+type alertLogEntry struct {
+	Timestamp int64    `json:"timestamp"`
+	Level     int64    `json:"level"`
+	Type      int64    `json:"type"`
+	Codes     []string `json:"codes"`
+	Text      string   `json:"text"`
+}
+
+type alertLogErrors struct {
+	Count  int             `json:"count"`
+	Errors []alertLogEntry `json:"errors"`
+}
+
+// AlertLogErrorsHandler 返回上次轮询以来告警日志中新出现的错误，每条记录只上报一次
+// 首次轮询只记录基线，不返回历史错误
+func AlertLogErrorsHandler(ctx context.Context, s Database, params map[string]string, _ ...string) (interface{}, error) {
+	include, err := compileOptionalRegex(params["Include"])
+	if err != nil {
+		return nil, zbxerr.ErrorInvalidParams.Wrap(err)
+	}
+
+	exclude, err := compileOptionalRegex(params["Exclude"])
+	if err != nil {
+		return nil, zbxerr.ErrorInvalidParams.Wrap(err)
+	}
+
+	query, err := alertLogQuery.For(s.Version())
+	if err != nil {
+		return nil, err
+	}
+
+	res := alertLogErrors{Errors: make([]alertLogEntry, 0)}
+
+	// 不同过滤条件的监控项各自维护高水位线，互不影响
+	stateKey := alertLogStateKey + "|" + params["Include"] + "|" + params["Exclude"]
+
+	err = s.State().Do(stateKey, func(prev interface{}) (interface{}, error) {
+		mark, ok := prev.(alertLogMark)
+		if !ok {
+			var baseline time.Time
+
+			if err := s.QueryRow(ctx, alertLogBaselineQuery).Scan(&baseline); err != nil {
+				return nil, zbxerr.ErrorCannotFetchData.Wrap(err)
+			}
+
+			return alertLogMark{ts: baseline}, nil
+		}
+
+		return readAlertLog(ctx, s, query, mark, include, exclude, &res)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res.Count = len(res.Errors)
+
+	jsonRes, err := json.Marshal(res)
+	if err != nil {
+		return nil, zbxerr.ErrorCannotMarshalJSON.Wrap(err)
+	}
+
+	return string(jsonRes), nil
+}
+
+func readAlertLog(
+	ctx context.Context, s Database, query string, mark alertLogMark, include, exclude *regexp.Regexp, res *alertLogErrors,
+) (alertLogMark, error) {
+	rows, err := s.Query(ctx, query, mark.ts)
+	if err != nil {
+		return mark, zbxerr.ErrorCannotFetchData.Wrap(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			ts          time.Time
+			recordID    int64
+			entry       alertLogEntry
+			level, kind sql.NullInt64
+			text        sql.NullString
+		)
+
+		if err = rows.Scan(&ts, &recordID, &entry.Timestamp, &level, &kind, &text); err != nil {
+			return mark, zbxerr.ErrorCannotFetchData.Wrap(err)
+		}
+
+		// 同一时间戳的记录按record_id去重
+		if ts.Equal(mark.ts) && recordID <= mark.recordID {
+			continue
+		}
+
+		mark = alertLogMark{ts: ts, recordID: recordID}
+
+		entry.Level = level.Int64
+		entry.Type = kind.Int64
+		entry.Text = strings.TrimSpace(text.String)
+		entry.Codes = filterOraCodes(oraCodeRegex.FindAllString(entry.Text, -1), include, exclude)
+
+		if len(entry.Codes) == 0 && (include != nil || strings.Contains(entry.Text, "ORA-")) {
+			continue
+		}
+
+		res.Errors = append(res.Errors, entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return mark, zbxerr.ErrorCannotFetchData.Wrap(err)
+	}
+
+	return mark, nil
+}
+
+// filterOraCodes 按include/exclude正则过滤ORA错误码并去重
+func filterOraCodes(codes []string, include, exclude *regexp.Regexp) []string {
+	out := make([]string, 0, len(codes))
+	seen := make(map[string]bool)
+
+	for _, code := range codes {
+		if seen[code] {
+			continue
+		}
+
+		seen[code] = true
+
+		if include != nil && !include.MatchString(code) {
+			continue
+		}
+
+		if exclude != nil && exclude.MatchString(code) {
+			continue
+		}
+
+		out = append(out, code)
+	}
+
+	return out
+}
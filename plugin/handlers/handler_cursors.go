@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"git.zabbix.com/ap/plugin-support/zbxerr"
+)
+
+const cursorsStateKey = "cursors.hard_parses"
+
+const cursorsParamsQuery = `
+SELECT NVL(MAX(DECODE(name, 'open_cursors', TO_NUMBER(value))), 0),
+       NVL(MAX(DECODE(name, 'session_cached_cursors', TO_NUMBER(value))), 0)
+  FROM v$parameter
+ WHERE name IN ('open_cursors', 'session_cached_cursors')`
+
+const cursorsTopSessionsQuery = `
+SELECT *
+  FROM (SELECT s.sid, s.serial#, s.username, s.program, st.value
+          FROM v$sesstat st
+          JOIN v$statname n ON n.statistic# = st.statistic#
+          JOIN v$session s ON s.sid = st.sid
+         WHERE n.name = 'opened cursors current'
+         ORDER BY st.value DESC)
+ WHERE ROWNUM <= :1`
+
+const cursorsCachedQuery = `
+SELECT NVL(MAX(st.value), 0)
+  FROM v$sesstat st
+  JOIN v$statname n ON n.statistic# = st.statistic#
+ WHERE n.name = 'session cursor cache count'`
+
+const libraryCacheQuery = `
+SELECT NVL(SUM(pinhits) / NULLIF(SUM(pins), 0) * 100, 100),
+       NVL(SUM(reloads), 0),
+       NVL(SUM(invalidations), 0)
+  FROM v$librarycache`
+
+const hardParsesQuery = `SELECT value FROM v$sysstat WHERE name = 'parse count (hard)'`
+
+type cursorsSample struct {
+	ts    time.Time
+	value int64
+}
+
+type cursorsSession struct {
+	SID      int64   `json:"sid"`
+	Serial   int64   `json:"serial"`
+	Username string  `json:"username"`
+	Program  string  `json:"program"`
+	Open     int64   `json:"open"`
+	PUsed    float64 `json:"pused"`
+}
+
+type cursorsStats struct {
+	OpenCursors          int64            `json:"open_cursors"`
+	MaxOpen              int64            `json:"max_open"`
+	MaxOpenPUsed         float64          `json:"max_open_pused"`
+	SessionCachedCursors int64            `json:"session_cached_cursors"`
+	MaxCached            int64            `json:"max_cached"`
+	MaxCachedPUsed       float64          `json:"max_cached_pused"`
+	LibraryCacheHitRatio float64          `json:"library_cache_hit_ratio"`
+	LibraryCacheReloads  int64            `json:"library_cache_reloads"`
+	LibraryCacheInvalid  int64            `json:"library_cache_invalidations"`
+	HardParses           int64            `json:"hard_parses"`
+	HardParsesPerSecond  *float64         `json:"hard_parses_per_second"`
+	TopSessions          []cursorsSession `json:"top_sessions"`
+}
+
+// CursorsStatsHandler 返回会话打开游标数与open_cursors的对比、会话游标缓存使用情况和库缓存统计
+// 硬解析速率按两次轮询之间的增量计算，首次轮询为null
+func CursorsStatsHandler(ctx context.Context, s Database, params map[string]string, _ ...string) (interface{}, error) {
+	n, err := strconv.Atoi(params["N"])
+	if err != nil {
+		return nil, zbxerr.ErrorInvalidParams.Wrap(err)
+	}
+
+	res := cursorsStats{TopSessions: make([]cursorsSession, 0)}
+
+	err = s.QueryRow(ctx, cursorsParamsQuery).Scan(&res.OpenCursors, &res.SessionCachedCursors)
+	if err != nil {
+		return nil, zbxerr.ErrorCannotFetchData.Wrap(err)
+	}
+
+	if err = getCursorsTopSessions(ctx, s, n, &res); err != nil {
+		return nil, err
+	}
+
+	if err = s.QueryRow(ctx, cursorsCachedQuery).Scan(&res.MaxCached); err != nil {
+		return nil, zbxerr.ErrorCannotFetchData.Wrap(err)
+	}
+
+	if res.SessionCachedCursors > 0 {
+		res.MaxCachedPUsed = float64(res.MaxCached) / float64(res.SessionCachedCursors) * 100
+	}
+
+	err = s.QueryRow(ctx, libraryCacheQuery).Scan(&res.LibraryCacheHitRatio, &res.LibraryCacheReloads,
+		&res.LibraryCacheInvalid)
+	if err != nil {
+		return nil, zbxerr.ErrorCannotFetchData.Wrap(err)
+	}
+
+	err = s.State().Do(cursorsStateKey, func(prev interface{}) (interface{}, error) {
+		cur := cursorsSample{ts: time.Now()}
+
+		if err := s.QueryRow(ctx, hardParsesQuery).Scan(&cur.value); err != nil {
+			return nil, zbxerr.ErrorCannotFetchData.Wrap(err)
+		}
+
+		res.HardParses = cur.value
+
+		if last, ok := prev.(cursorsSample); ok && cur.value >= last.value {
+			if interval := cur.ts.Sub(last.ts).Seconds(); interval > 0 {
+				rate := float64(cur.value-last.value) / interval
+				res.HardParsesPerSecond = &rate
+			}
+		}
+
+		return cur, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	jsonRes, err := json.Marshal(res)
+	if err != nil {
+		return nil, zbxerr.ErrorCannotMarshalJSON.Wrap(err)
+	}
+
+	return string(jsonRes), nil
+}
+
+func getCursorsTopSessions(ctx context.Context, s Database, n int, res *cursorsStats) error {
+	rows, err := s.Query(ctx, cursorsTopSessionsQuery, n)
+	if err != nil {
+		return zbxerr.ErrorCannotFetchData.Wrap(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			sess              cursorsSession
+			username, program sql.NullString
+		)
+
+		if err = rows.Scan(&sess.SID, &sess.Serial, &username, &program, &sess.Open); err != nil {
+			return zbxerr.ErrorCannotFetchData.Wrap(err)
+		}
+
+		sess.Username = username.String
+		sess.Program = program.String
+
+		if res.OpenCursors > 0 {
+			sess.PUsed = float64(sess.Open) / float64(res.OpenCursors) * 100
+		}
+
+		if sess.Open > res.MaxOpen {
+			res.MaxOpen = sess.Open
+			res.MaxOpenPUsed = sess.PUsed
+		}
+
+		res.TopSessions = append(res.TopSessions, sess)
+	}
+
+	if err = rows.Err(); err != nil {
+		return zbxerr.ErrorCannotFetchData.Wrap(err)
+	}
+
+	return nil
+}
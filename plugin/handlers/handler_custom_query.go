@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"git.zabbix.com/ap/plugin-support/zbxerr"
+)
+
+// CustomQueryHandler 执行CustomQueriesPath目录中的自定义查询，键的附加参数依次作为绑定变量:1、:2…传入
+// 结果集以对象数组返回，列名转为小写
+func CustomQueryHandler(ctx context.Context, s Database, params map[string]string,
+	extraParams ...string) (interface{}, error) {
+	if CustomQueries == nil {
+		return nil, zbxerr.ErrorInvalidParams.Wrap(fmt.Errorf("custom queries path is not configured"))
+	}
+
+	name := params["QueryName"]
+
+	query, ok := CustomQueries.Get(name)
+	if !ok {
+		return nil, zbxerr.ErrorInvalidParams.Wrap(fmt.Errorf("custom query %q not found", name))
+	}
+
+	args := make([]interface{}, 0, len(extraParams))
+	for _, p := range extraParams {
+		args = append(args, p)
+	}
+
+	rows, err := s.Query(ctx, query, args...)
+	if err != nil {
+		return nil, zbxerr.ErrorCannotFetchData.Wrap(err)
+	}
+	defer rows.Close()
+
+	_, res, err := fetchRows(rows)
+	if err != nil {
+		return nil, zbxerr.ErrorCannotFetchData.Wrap(err)
+	}
+
+	jsonRes, err := json.Marshal(res)
+	if err != nil {
+		return nil, zbxerr.ErrorCannotMarshalJSON.Wrap(err)
+	}
+
+	return string(jsonRes), nil
+}
@@ -0,0 +1,291 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"git.zabbix.com/ap/plugin-support/zbxerr"
+)
+
+const datafilesIOStateKey = "datafiles.io"
+
+// readtim/writetim单位为厘秒，依赖timed_statistics参数
+const filestatQueryTmpl = `
+SELECT 'datafile', f.file#, d.name, t.name, f.phyrds, f.phywrts,
+       f.phyblkrd * d.block_size, f.phyblkwrt * d.block_size, f.readtim, f.writetim
+  FROM v$filestat f
+  JOIN v$datafile d ON d.file# = f.file#
+  JOIN v$tablespace t ON t.ts# = d.ts#%[1]s
+UNION ALL
+SELECT 'tempfile', f.file#, d.name, t.name, f.phyrds, f.phywrts,
+       f.phyblkrd * d.block_size, f.phyblkwrt * d.block_size, f.readtim, f.writetim
+  FROM v$tempstat f
+  JOIN v$tempfile d ON d.file# = f.file#
+  JOIN v$tablespace t ON t.ts# = d.ts#%[1]s`
+
+var filestatQuery = NewVersionedQuery("datafiles I/O").
+	Since("11.2", fmt.Sprintf(filestatQueryTmpl, "")).
+	Since("12.1", fmt.Sprintf(filestatQueryTmpl, conIDJoin))
+
+const conIDJoin = " AND t.con_id = d.con_id"
+
+const iostatFileQuery = `
+SELECT DECODE(filetype_name, 'Data File', 'datafile', 'tempfile'), file_no,
+       small_read_reqs, small_write_reqs, large_read_reqs, large_write_reqs
+  FROM v$iostat_file
+ WHERE filetype_name IN ('Data File', 'Temp File')`
+
+const datafilesStatusQuery = `
+SELECT d.name,
+       d.status,
+       NVL2(r.file#, 1, 0),
+       DECODE(b.status, 'ACTIVE', 1, 0)
+  FROM v$datafile d
+  LEFT JOIN v$recover_file r ON r.file# = d.file#
+  LEFT JOIN v$backup b ON b.file# = d.file#
+ WHERE d.status IN ('OFFLINE', 'RECOVER', 'SYSOFF')
+    OR r.file# IS NOT NULL
+    OR b.status = 'ACTIVE'`
+
+type fileIOSample struct {
+	name        string
+	tablespace  string
+	reads       int64
+	writes      int64
+	readBytes   int64
+	writeBytes  int64
+	readTime    int64
+	writeTime   int64
+	smallReads  int64
+	smallWrites int64
+	largeReads  int64
+	largeWrites int64
+}
+
+type fileIOSnapshot struct {
+	ts    time.Time
+	files map[string]fileIOSample
+}
+
+type fileIO struct {
+	Name         string   `json:"name"`
+	Tablespace   string   `json:"tablespace"`
+	Reads        int64    `json:"reads"`
+	Writes       int64    `json:"writes"`
+	ReadBytes    int64    `json:"read_bytes"`
+	WriteBytes   int64    `json:"write_bytes"`
+	AvgReadTime  *float64 `json:"avg_read_time"`
+	AvgWriteTime *float64 `json:"avg_write_time"`
+	SmallReads   int64    `json:"small_reads"`
+	SmallWrites  int64    `json:"small_writes"`
+	LargeReads   int64    `json:"large_reads"`
+	LargeWrites  int64    `json:"large_writes"`
+}
+
+type datafilesIO struct {
+	Interval float64           `json:"interval"`
+	Files    map[string]fileIO `json:"files"`
+	Offline  []string          `json:"offline"`
+	Recovery []string          `json:"recovery"`
+	Backup   []string          `json:"backup"`
+}
+
+// DatafilesIOHandler 返回两次轮询之间每个数据文件和临时文件的I/O增量，键为"<文件类型>.<文件号>"
+// 平均读写时间单位为毫秒，首次轮询只记录基线，增量均为0
+func DatafilesIOHandler(ctx context.Context, s Database, _ map[string]string, _ ...string) (interface{}, error) {
+	res := datafilesIO{
+		Files:    make(map[string]fileIO),
+		Offline:  make([]string, 0),
+		Recovery: make([]string, 0),
+		Backup:   make([]string, 0),
+	}
+
+	err := s.State().Do(datafilesIOStateKey, func(prev interface{}) (interface{}, error) {
+		cur, err := getFileIOSnapshot(ctx, s)
+		if err != nil {
+			return nil, err
+		}
+
+		last, ok := prev.(*fileIOSnapshot)
+		if ok {
+			res.Interval = cur.ts.Sub(last.ts).Seconds()
+		}
+
+		for id, c := range cur.files {
+			// 首次轮询或新增的文件以当前值作为基线
+			p := c
+			if ok {
+				if lp, found := last.files[id]; found {
+					p = lp
+				}
+			}
+
+			res.Files[id] = fileIODelta(p, c)
+		}
+
+		return cur, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err = getDatafilesStatus(ctx, s, &res); err != nil {
+		return nil, err
+	}
+
+	jsonRes, err := json.Marshal(res)
+	if err != nil {
+		return nil, zbxerr.ErrorCannotMarshalJSON.Wrap(err)
+	}
+
+	return string(jsonRes), nil
+}
+
+func getFileIOSnapshot(ctx context.Context, s Database) (*fileIOSnapshot, error) {
+	query, err := filestatQuery.For(s.Version())
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.Query(ctx, query)
+	if err != nil {
+		return nil, zbxerr.ErrorCannotFetchData.Wrap(err)
+	}
+	defer rows.Close()
+
+	snap := &fileIOSnapshot{ts: time.Now(), files: make(map[string]fileIOSample)}
+
+	for rows.Next() {
+		var (
+			fileType string
+			fileID   int64
+			f        fileIOSample
+		)
+
+		err = rows.Scan(&fileType, &fileID, &f.name, &f.tablespace, &f.reads, &f.writes, &f.readBytes, &f.writeBytes,
+			&f.readTime, &f.writeTime)
+		if err != nil {
+			return nil, zbxerr.ErrorCannotFetchData.Wrap(err)
+		}
+
+		snap.files[fileType+"."+strconv.FormatInt(fileID, 10)] = f
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, zbxerr.ErrorCannotFetchData.Wrap(err)
+	}
+
+	if err = getIOStatFile(ctx, s, snap); err != nil {
+		return nil, err
+	}
+
+	return snap, nil
+}
+
+func getIOStatFile(ctx context.Context, s Database, snap *fileIOSnapshot) error {
+	rows, err := s.Query(ctx, iostatFileQuery)
+	if err != nil {
+		return zbxerr.ErrorCannotFetchData.Wrap(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			fileType                                         string
+			fileID                                           int64
+			smallReads, smallWrites, largeReads, largeWrites int64
+		)
+
+		if err = rows.Scan(&fileType, &fileID, &smallReads, &smallWrites, &largeReads, &largeWrites); err != nil {
+			return zbxerr.ErrorCannotFetchData.Wrap(err)
+		}
+
+		id := fileType + "." + strconv.FormatInt(fileID, 10)
+
+		f, ok := snap.files[id]
+		if !ok {
+			continue
+		}
+
+		f.smallReads, f.smallWrites, f.largeReads, f.largeWrites = smallReads, smallWrites, largeReads, largeWrites
+		snap.files[id] = f
+	}
+
+	if err = rows.Err(); err != nil {
+		return zbxerr.ErrorCannotFetchData.Wrap(err)
+	}
+
+	return nil
+}
+
+// fileIODelta 计算两次采样之间的增量，实例重启后计数器变小时以当前值作为增量
+func fileIODelta(p, c fileIOSample) fileIO {
+	if c.reads < p.reads || c.writes < p.writes {
+		p = fileIOSample{}
+	}
+
+	res := fileIO{
+		Name:        c.name,
+		Tablespace:  c.tablespace,
+		Reads:       c.reads - p.reads,
+		Writes:      c.writes - p.writes,
+		ReadBytes:   c.readBytes - p.readBytes,
+		WriteBytes:  c.writeBytes - p.writeBytes,
+		SmallReads:  c.smallReads - p.smallReads,
+		SmallWrites: c.smallWrites - p.smallWrites,
+		LargeReads:  c.largeReads - p.largeReads,
+		LargeWrites: c.largeWrites - p.largeWrites,
+	}
+
+	if res.Reads > 0 {
+		avg := float64(c.readTime-p.readTime) * 10 / float64(res.Reads)
+		res.AvgReadTime = &avg
+	}
+
+	if res.Writes > 0 {
+		avg := float64(c.writeTime-p.writeTime) * 10 / float64(res.Writes)
+		res.AvgWriteTime = &avg
+	}
+
+	return res
+}
+
+func getDatafilesStatus(ctx context.Context, s Database, res *datafilesIO) error {
+	rows, err := s.Query(ctx, datafilesStatusQuery)
+	if err != nil {
+		return zbxerr.ErrorCannotFetchData.Wrap(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			name, status     string
+			recovery, backup int
+		)
+
+		if err = rows.Scan(&name, &status, &recovery, &backup); err != nil {
+			return zbxerr.ErrorCannotFetchData.Wrap(err)
+		}
+
+		if status == "OFFLINE" || status == "SYSOFF" {
+			res.Offline = append(res.Offline, name)
+		}
+
+		if status == "RECOVER" || recovery == 1 {
+			res.Recovery = append(res.Recovery, name)
+		}
+
+		if backup == 1 {
+			res.Backup = append(res.Backup, name)
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return zbxerr.ErrorCannotFetchData.Wrap(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"git.zabbix.com/ap/plugin-support/zbxerr"
+)
+
+const flashbackStatusQuery = `
+SELECT d.flashback_on,
+       NVL((SELECT TO_NUMBER(value) FROM v$parameter WHERE name = 'db_flashback_retention_target'), 0)
+  FROM v$database d`
+
+// 实际可回退窗口为当前时间与最早闪回时间之差，单位为分钟
+const flashbackLogQuery = `
+SELECT oldest_flashback_scn,
+       ROUND((CAST(SYS_EXTRACT_UTC(CAST(oldest_flashback_time AS TIMESTAMP WITH LOCAL TIME ZONE)) AS DATE) -
+             DATE '1970-01-01') * 86400),
+       ROUND((SYSDATE - oldest_flashback_time) * 1440),
+       flashback_size,
+       estimated_flashback_size
+  FROM v$flashback_database_log`
+
+const restorePointsQuery = `
+SELECT name,
+       guarantee_flashback_database,
+       scn,
+       ROUND((SYSDATE - CAST(time AS DATE)) * 86400),
+       storage_size
+  FROM v$restore_point
+ ORDER BY time`
+
+type restorePoint struct {
+	Name        string `json:"name"`
+	Guaranteed  bool   `json:"guaranteed"`
+	SCN         int64  `json:"scn"`
+	Age         int64  `json:"age"`
+	StorageSize int64  `json:"storage_size"`
+}
+
+type flashbackStats struct {
+	FlashbackOn           bool           `json:"flashback_on"`
+	OldestSCN             *int64         `json:"oldest_scn"`
+	OldestTime            *int64         `json:"oldest_time"`
+	RetentionTarget       int64          `json:"retention_target"`
+	RetentionWindow       *int64         `json:"retention_window"`
+	LogSize               int64          `json:"log_size"`
+	EstimatedLogSize      int64          `json:"estimated_log_size"`
+	GuaranteedCount       int            `json:"guaranteed_count"`
+	GuaranteedStorageSize int64          `json:"guaranteed_storage_size"`
+	RestorePoints         []restorePoint `json:"restore_points"`
+}
+
+// FlashbackStatsHandler 返回闪回数据库状态、保留目标与实际可回退窗口（分钟）以及还原点列表
+// 还原点的age单位为秒
+func FlashbackStatsHandler(ctx context.Context, s Database, _ map[string]string, _ ...string) (interface{}, error) {
+	var (
+		res         = flashbackStats{RestorePoints: make([]restorePoint, 0)}
+		flashbackOn string
+	)
+
+	err := s.QueryRow(ctx, flashbackStatusQuery).Scan(&flashbackOn, &res.RetentionTarget)
+	if err != nil {
+		return nil, zbxerr.ErrorCannotFetchData.Wrap(err)
+	}
+
+	res.FlashbackOn = flashbackOn == "YES"
+
+	if err = getFlashbackLog(ctx, s, &res); err != nil {
+		return nil, err
+	}
+
+	if err = getRestorePoints(ctx, s, &res); err != nil {
+		return nil, err
+	}
+
+	jsonRes, err := json.Marshal(res)
+	if err != nil {
+		return nil, zbxerr.ErrorCannotMarshalJSON.Wrap(err)
+	}
+
+	return string(jsonRes), nil
+}
+
+// getFlashbackLog 闪回关闭且没有保证还原点时v$flashback_database_log没有数据
+func getFlashbackLog(ctx context.Context, s Database, res *flashbackStats) error {
+	var (
+		scn, oldest, window sql.NullInt64
+		size, estimated     sql.NullInt64
+	)
+
+	err := s.QueryRow(ctx, flashbackLogQuery).Scan(&scn, &oldest, &window, &size, &estimated)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+
+	if err != nil {
+		return zbxerr.ErrorCannotFetchData.Wrap(err)
+	}
+
+	res.OldestSCN = nullInt64Ptr(scn)
+	res.OldestTime = nullInt64Ptr(oldest)
+	res.RetentionWindow = nullInt64Ptr(window)
+	res.LogSize = size.Int64
+	res.EstimatedLogSize = estimated.Int64
+
+	return nil
+}
+
+func getRestorePoints(ctx context.Context, s Database, res *flashbackStats) error {
+	rows, err := s.Query(ctx, restorePointsQuery)
+	if err != nil {
+		return zbxerr.ErrorCannotFetchData.Wrap(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			rp          restorePoint
+			guaranteed  string
+			storageSize sql.NullInt64
+		)
+
+		if err = rows.Scan(&rp.Name, &guaranteed, &rp.SCN, &rp.Age, &storageSize); err != nil {
+			return zbxerr.ErrorCannotFetchData.Wrap(err)
+		}
+
+		rp.Guaranteed = guaranteed == "YES"
+		rp.StorageSize = storageSize.Int64
+
+		if rp.Guaranteed {
+			res.GuaranteedCount++
+			res.GuaranteedStorageSize += rp.StorageSize
+		}
+
+		res.RestorePoints = append(res.RestorePoints, rp)
+	}
+
+	if err = rows.Err(); err != nil {
+		return zbxerr.ErrorCannotFetchData.Wrap(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"git.zabbix.com/ap/plugin-support/zbxerr"
+)
+
+// 18c起version只包含主版本号，完整的版本号在version_full中
+var versionQuery = NewVersionedQuery("database version").
+	Since("11.2", `SELECT version FROM v$instance`).
+	Since("18", `SELECT version_full FROM v$instance`)
+
+// 12.1之前没有dba_registry_sqlpatch，补丁记录在dba_registry_history中且没有状态
+var sqlPatchesQuery = NewVersionedQuery("SQL patches").
+	Since("11.2", `
+SELECT NVL(id, 0),
+       action,
+       NULL,
+       comments,
+       ROUND((CAST(SYS_EXTRACT_UTC(CAST(action_time AS TIMESTAMP WITH TIME ZONE)) AS DATE) - DATE '1970-01-01') * 86400)
+  FROM dba_registry_history
+ ORDER BY action_time`).
+	Since("12.1", `
+SELECT patch_id,
+       action,
+       status,
+       description,
+       ROUND((CAST(SYS_EXTRACT_UTC(CAST(action_time AS TIMESTAMP WITH TIME ZONE)) AS DATE) - DATE '1970-01-01') * 86400)
+  FROM dba_registry_sqlpatch
+ ORDER BY action_time`)
+
+const componentsQuery = `
+SELECT comp_id, comp_name, version, status
+  FROM dba_registry
+ ORDER BY comp_id`
+
+type sqlPatch struct {
+	PatchID     int64  `json:"patch_id"`
+	Action      string `json:"action"`
+	Status      string `json:"status"`
+	Description string `json:"description"`
+	ActionTime  *int64 `json:"action_time"`
+}
+
+type component struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Status  string `json:"status"`
+}
+
+type inventory struct {
+	Version           string      `json:"version"`
+	ReleaseUpdate     string      `json:"release_update"`
+	Patches           []sqlPatch  `json:"patches"`
+	Components        []component `json:"components"`
+	InvalidComponents []string    `json:"invalid_components"`
+}
+
+// InventoryHandler 返回数据库版本、已安装的SQL补丁和组件状态
+// release_update为最近一次成功应用且之后未被回退的补丁描述，12.1之前为相应的补丁说明
+func InventoryHandler(ctx context.Context, s Database, _ map[string]string, _ ...string) (interface{}, error) {
+	res := inventory{
+		Patches:           make([]sqlPatch, 0),
+		Components:        make([]component, 0),
+		InvalidComponents: make([]string, 0),
+	}
+
+	query, err := versionQuery.For(s.Version())
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.QueryRow(ctx, query).Scan(&res.Version); err != nil {
+		return nil, zbxerr.ErrorCannotFetchData.Wrap(err)
+	}
+
+	if err = getSQLPatches(ctx, s, &res); err != nil {
+		return nil, err
+	}
+
+	if err = getComponents(ctx, s, &res); err != nil {
+		return nil, err
+	}
+
+	jsonRes, err := json.Marshal(res)
+	if err != nil {
+		return nil, zbxerr.ErrorCannotMarshalJSON.Wrap(err)
+	}
+
+	return string(jsonRes), nil
+}
+
+func getSQLPatches(ctx context.Context, s Database, res *inventory) error {
+	query, err := sqlPatchesQuery.For(s.Version())
+	if err != nil {
+		return err
+	}
+
+	rows, err := s.Query(ctx, query)
+	if err != nil {
+		return zbxerr.ErrorCannotFetchData.Wrap(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			p                           sqlPatch
+			action, status, description sql.NullString
+			actionTime                  sql.NullInt64
+		)
+
+		if err = rows.Scan(&p.PatchID, &action, &status, &description, &actionTime); err != nil {
+			return zbxerr.ErrorCannotFetchData.Wrap(err)
+		}
+
+		p.Action = action.String
+		p.Status = status.String
+		p.Description = description.String
+		p.ActionTime = nullInt64Ptr(actionTime)
+
+		res.Patches = append(res.Patches, p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return zbxerr.ErrorCannotFetchData.Wrap(err)
+	}
+
+	res.ReleaseUpdate = releaseUpdate(res.Patches)
+
+	return nil
+}
+
+// releaseUpdate 返回按时间排列的补丁记录中最近一次仍然生效的补丁描述
+// 每个补丁只看其最后一次操作，之后被回退或应用失败的补丁不计入
+func releaseUpdate(patches []sqlPatch) string {
+	last := make(map[int64]int, len(patches))
+
+	for i, p := range patches {
+		last[p.PatchID] = i
+	}
+
+	var ru string
+
+	for i, p := range patches {
+		if last[p.PatchID] == i && p.Action == "APPLY" && (p.Status == "SUCCESS" || p.Status == "") {
+			ru = p.Description
+		}
+	}
+
+	return ru
+}
+
+func getComponents(ctx context.Context, s Database, res *inventory) error {
+	rows, err := s.Query(ctx, componentsQuery)
+	if err != nil {
+		return zbxerr.ErrorCannotFetchData.Wrap(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			c               component
+			version, status sql.NullString
+		)
+
+		if err = rows.Scan(&c.ID, &c.Name, &version, &status); err != nil {
+			return zbxerr.ErrorCannotFetchData.Wrap(err)
+		}
+
+		c.Version = version.String
+		c.Status = status.String
+
+		if c.Status == "INVALID" {
+			res.InvalidComponents = append(res.InvalidComponents, c.ID)
+		}
+
+		res.Components = append(res.Components, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return zbxerr.ErrorCannotFetchData.Wrap(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"git.zabbix.com/ap/plugin-support/zbxerr"
+)
+
+// 时间统一转换为UTC的unix时间戳，持续时间转换为秒
+// %s为最近一次失败的错误信息表达式，随版本不同
+const schedulerJobsQueryTmpl = `
+SELECT j.owner,
+       j.job_name,
+       j.enabled,
+       j.state,
+       ROUND((CAST(SYS_EXTRACT_UTC(j.last_start_date) AS DATE) - DATE '1970-01-01') * 86400),
+       EXTRACT(DAY FROM j.last_run_duration) * 86400 + EXTRACT(HOUR FROM j.last_run_duration) * 3600 +
+       EXTRACT(MINUTE FROM j.last_run_duration) * 60 + EXTRACT(SECOND FROM j.last_run_duration),
+       ROUND((CAST(SYS_EXTRACT_UTC(j.next_run_date) AS DATE) - DATE '1970-01-01') * 86400),
+       NVL(j.failure_count, 0),
+       NVL(f.failed_runs, 0),
+       f.last_error_code,
+       f.last_error
+  FROM dba_scheduler_jobs j
+  LEFT JOIN (SELECT owner,
+                    job_name,
+                    COUNT(*) AS failed_runs,
+                    MAX(error#) KEEP (DENSE_RANK LAST ORDER BY log_date) AS last_error_code,
+                    MAX(%s) KEEP (DENSE_RANK LAST ORDER BY log_date) AS last_error
+               FROM dba_scheduler_job_run_details
+              WHERE status = 'FAILED'
+                AND log_date > SYSTIMESTAMP - NUMTODSINTERVAL(:1, 'HOUR')
+              GROUP BY owner, job_name) f
+    ON f.owner = j.owner
+   AND f.job_name = j.job_name`
+
+// 12.2起additional_info为CLOB，错误信息优先取errors列，截断须使用DBMS_LOB.SUBSTR
+var schedulerJobsQuery = NewVersionedQuery("scheduler jobs").
+	Since("11.2", fmt.Sprintf(schedulerJobsQueryTmpl, "DBMS_LOB.SUBSTR(additional_info, 1000, 1)")).
+	Since("12.2", fmt.Sprintf(schedulerJobsQueryTmpl,
+		"COALESCE(DBMS_LOB.SUBSTR(errors, 1000, 1), DBMS_LOB.SUBSTR(additional_info, 1000, 1))"))
+
+const legacyJobsQuery = `
+SELECT job,
+       schema_user,
+       broken,
+       NVL(failures, 0),
+       ROUND((CAST(SYS_EXTRACT_UTC(CAST(next_date AS TIMESTAMP WITH LOCAL TIME ZONE)) AS DATE) - DATE '1970-01-01') * 86400),
+       SUBSTR(what, 1, 200)
+  FROM dba_jobs`
+
+type schedulerJob struct {
+	Owner           string   `json:"owner"`
+	Name            string   `json:"job_name"`
+	Enabled         bool     `json:"enabled"`
+	State           string   `json:"state"`
+	LastStart       *int64   `json:"last_start"`
+	LastRunDuration *float64 `json:"last_run_duration"`
+	NextRun         *int64   `json:"next_run"`
+	FailureCount    int64    `json:"failure_count"`
+	FailedRuns      int64    `json:"failed_runs"`
+	LastErrorCode   *int64   `json:"last_error_code"`
+	LastError       string   `json:"last_error"`
+}
+
+type legacyJob struct {
+	Job        int64  `json:"job"`
+	SchemaUser string `json:"schema_user"`
+	Broken     bool   `json:"broken"`
+	Failures   int64  `json:"failures"`
+	NextRun    *int64 `json:"next_run"`
+	What       string `json:"what"`
+}
+
+type jobsStats struct {
+	Scheduler  map[string]schedulerJob `json:"scheduler"`
+	Legacy     map[string]legacyJob    `json:"legacy"`
+	FailedRuns int64                   `json:"failed_runs"`
+	Broken     int64                   `json:"broken"`
+}
+
+func JobsStatsHandler(ctx context.Context, s Database, params map[string]string, _ ...string) (interface{}, error) {
+	hours, err := strconv.Atoi(params["Hours"])
+	if err != nil {
+		return nil, zbxerr.ErrorInvalidParams.Wrap(err)
+	}
+
+	stats := jobsStats{
+		Scheduler: make(map[string]schedulerJob),
+		Legacy:    make(map[string]legacyJob),
+	}
+
+	if err = getSchedulerJobs(ctx, s, hours, &stats); err != nil {
+		return nil, err
+	}
+
+	if err = getLegacyJobs(ctx, s, &stats); err != nil {
+		return nil, err
+	}
+
+	jsonRes, err := json.Marshal(stats)
+	if err != nil {
+		return nil, zbxerr.ErrorCannotMarshalJSON.Wrap(err)
+	}
+
+	return string(jsonRes), nil
+}
+
+func getSchedulerJobs(ctx context.Context, s Database, hours int, stats *jobsStats) error {
+	query, err := schedulerJobsQuery.For(s.Version())
+	if err != nil {
+		return err
+	}
+
+	rows, err := s.Query(ctx, query, hours)
+	if err != nil {
+		return zbxerr.ErrorCannotFetchData.Wrap(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			job                        schedulerJob
+			enabled, state, lastError  sql.NullString
+			lastStart, nextRun, errNum sql.NullInt64
+			duration                   sql.NullFloat64
+		)
+
+		err = rows.Scan(&job.Owner, &job.Name, &enabled, &state, &lastStart, &duration, &nextRun,
+			&job.FailureCount, &job.FailedRuns, &errNum, &lastError)
+		if err != nil {
+			return zbxerr.ErrorCannotFetchData.Wrap(err)
+		}
+
+		job.Enabled = enabled.String == "TRUE"
+		job.State = state.String
+		job.LastStart = nullInt64Ptr(lastStart)
+		job.LastRunDuration = nullFloat64Ptr(duration)
+		job.NextRun = nullInt64Ptr(nextRun)
+		job.LastErrorCode = nullInt64Ptr(errNum)
+		job.LastError = lastError.String
+
+		stats.Scheduler[job.Owner+"."+job.Name] = job
+		stats.FailedRuns += job.FailedRuns
+	}
+
+	if err = rows.Err(); err != nil {
+		return zbxerr.ErrorCannotFetchData.Wrap(err)
+	}
+
+	return nil
+}
+
+func getLegacyJobs(ctx context.Context, s Database, stats *jobsStats) error {
+	rows, err := s.Query(ctx, legacyJobsQuery)
+	if err != nil {
+		return zbxerr.ErrorCannotFetchData.Wrap(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			job          legacyJob
+			broken, what sql.NullString
+			nextRun      sql.NullInt64
+		)
+
+		if err = rows.Scan(&job.Job, &job.SchemaUser, &broken, &job.Failures, &nextRun, &what); err != nil {
+			return zbxerr.ErrorCannotFetchData.Wrap(err)
+		}
+
+		job.Broken = broken.String == "Y"
+		job.NextRun = nullInt64Ptr(nextRun)
+		job.What = what.String
+
+		stats.Legacy[strconv.FormatInt(job.Job, 10)] = job
+
+		if job.Broken {
+			stats.Broken++
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return zbxerr.ErrorCannotFetchData.Wrap(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strconv"
+
+	"git.zabbix.com/ap/plugin-support/zbxerr"
+)
+
+// 排除插件自身的监控会话，AUDSID在SYS和后台会话之间重复，只能按SID区分
+// 只查询本实例的v$session，SID在实例内唯一
+const longQueriesQuery = `
+SELECT sid,
+       serial#,
+       username,
+       sql_id,
+       last_call_et,
+       event,
+       module
+  FROM v$session
+ WHERE type = 'USER'
+   AND status = 'ACTIVE'
+   AND last_call_et > :1
+   AND sid <> TO_NUMBER(SYS_CONTEXT('USERENV', 'SID'))
+ ORDER BY last_call_et DESC`
+
+type longQuery struct {
+	SID      int64  `json:"sid"`
+	Serial   int64  `json:"serial"`
+	Username string `json:"username"`
+	SQLID    string `json:"sql_id"`
+	Elapsed  int64  `json:"elapsed"`
+	Event    string `json:"event"`
+	Module   string `json:"module"`
+}
+
+type longQueries struct {
+	Count    int         `json:"count"`
+	MaxTime  int64       `json:"max_time"`
+	Sessions []longQuery `json:"sessions"`
+}
+
+// LongQueriesHandler 返回当前调用运行时间超过阈值的活动用户会话
+func LongQueriesHandler(ctx context.Context, s Database, params map[string]string, _ ...string) (interface{}, error) {
+	seconds, err := strconv.Atoi(params["Seconds"])
+	if err != nil {
+		return nil, zbxerr.ErrorInvalidParams.Wrap(err)
+	}
+
+	rows, err := s.Query(ctx, longQueriesQuery, seconds)
+	if err != nil {
+		return nil, zbxerr.ErrorCannotFetchData.Wrap(err)
+	}
+	defer rows.Close()
+
+	res := longQueries{Sessions: make([]longQuery, 0)}
+
+	for rows.Next() {
+		var (
+			q                              longQuery
+			username, sqlID, event, module sql.NullString
+		)
+
+		if err = rows.Scan(&q.SID, &q.Serial, &username, &sqlID, &q.Elapsed, &event, &module); err != nil {
+			return nil, zbxerr.ErrorCannotFetchData.Wrap(err)
+		}
+
+		q.Username = username.String
+		q.SQLID = sqlID.String
+		q.Event = event.String
+		q.Module = module.String
+
+		if q.Elapsed > res.MaxTime {
+			res.MaxTime = q.Elapsed
+		}
+
+		res.Sessions = append(res.Sessions, q)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, zbxerr.ErrorCannotFetchData.Wrap(err)
+	}
+
+	res.Count = len(res.Sessions)
+
+	jsonRes, err := json.Marshal(res)
+	if err != nil {
+		return nil, zbxerr.ErrorCannotMarshalJSON.Wrap(err)
+	}
+
+	return string(jsonRes), nil
+}
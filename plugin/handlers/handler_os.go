@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"git.zabbix.com/ap/plugin-support/zbxerr"
+)
+
+const osStatsStateKey = "os.stats"
+
+const osStatsQuery = `
+SELECT stat_name, value
+  FROM v$osstat
+ WHERE stat_name IN ('NUM_CPUS', 'LOAD', 'BUSY_TIME', 'IDLE_TIME', 'USER_TIME', 'SYS_TIME', 'IOWAIT_TIME',
+                     'PHYSICAL_MEMORY_BYTES', 'VM_IN_BYTES', 'VM_OUT_BYTES')`
+
+type osStatsSnapshot struct {
+	ts    time.Time
+	stats map[string]float64
+}
+
+type osStats struct {
+	Interval       float64  `json:"interval"`
+	NumCPUs        int64    `json:"num_cpus"`
+	Load           float64  `json:"load"`
+	PhysicalMemory int64    `json:"physical_memory"`
+	Busy           *float64 `json:"busy"`
+	Idle           *float64 `json:"idle"`
+	User           *float64 `json:"user"`
+	Sys            *float64 `json:"sys"`
+	IOWait         *float64 `json:"iowait"`
+	SwapIn         *float64 `json:"swap_in"`
+	SwapOut        *float64 `json:"swap_out"`
+}
+
+// OSStatsHandler 返回v$osstat中的主机统计信息
+// CPU时间换算为两次轮询之间的百分比，换入换出换算为每秒字节数，首次轮询这些值为null
+func OSStatsHandler(ctx context.Context, s Database, _ map[string]string, _ ...string) (interface{}, error) {
+	var res osStats
+
+	err := s.State().Do(osStatsStateKey, func(prev interface{}) (interface{}, error) {
+		cur, err := getOSStatsSnapshot(ctx, s)
+		if err != nil {
+			return nil, err
+		}
+
+		res.NumCPUs = int64(cur.stats["NUM_CPUS"])
+		res.Load = cur.stats["LOAD"]
+		res.PhysicalMemory = int64(cur.stats["PHYSICAL_MEMORY_BYTES"])
+
+		if last, ok := prev.(*osStatsSnapshot); ok {
+			setOSStatsDeltas(&res, last, cur)
+		}
+
+		return cur, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	jsonRes, err := json.Marshal(res)
+	if err != nil {
+		return nil, zbxerr.ErrorCannotMarshalJSON.Wrap(err)
+	}
+
+	return string(jsonRes), nil
+}
+
+func getOSStatsSnapshot(ctx context.Context, s Database) (*osStatsSnapshot, error) {
+	rows, err := s.Query(ctx, osStatsQuery)
+	if err != nil {
+		return nil, zbxerr.ErrorCannotFetchData.Wrap(err)
+	}
+	defer rows.Close()
+
+	snap := &osStatsSnapshot{ts: time.Now(), stats: make(map[string]float64)}
+
+	for rows.Next() {
+		var (
+			name  string
+			value float64
+		)
+
+		if err = rows.Scan(&name, &value); err != nil {
+			return nil, zbxerr.ErrorCannotFetchData.Wrap(err)
+		}
+
+		snap.stats[name] = value
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, zbxerr.ErrorCannotFetchData.Wrap(err)
+	}
+
+	return snap, nil
+}
+
+// setOSStatsDeltas 以BUSY_TIME与IDLE_TIME增量之和作为总CPU时间计算各项百分比
+// 计数器变小（实例重启）时跳过本次计算
+func setOSStatsDeltas(res *osStats, last, cur *osStatsSnapshot) {
+	delta := func(name string) float64 {
+		return cur.stats[name] - last.stats[name]
+	}
+
+	res.Interval = cur.ts.Sub(last.ts).Seconds()
+
+	total := delta("BUSY_TIME") + delta("IDLE_TIME")
+	if total <= 0 || delta("BUSY_TIME") < 0 || delta("IDLE_TIME") < 0 {
+		return
+	}
+
+	percent := func(name string) *float64 {
+		if _, ok := cur.stats[name]; !ok {
+			return nil
+		}
+
+		v := delta(name) / total * 100
+
+		return &v
+	}
+
+	res.Busy = percent("BUSY_TIME")
+	res.Idle = percent("IDLE_TIME")
+	res.User = percent("USER_TIME")
+	res.Sys = percent("SYS_TIME")
+	res.IOWait = percent("IOWAIT_TIME")
+
+	perSecond := func(name string) *float64 {
+		if _, ok := cur.stats[name]; !ok || res.Interval <= 0 {
+			return nil
+		}
+
+		v := delta(name) / res.Interval
+
+		return &v
+	}
+
+	res.SwapIn = perSecond("VM_IN_BYTES")
+	res.SwapOut = perSecond("VM_OUT_BYTES")
+}
@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"git.zabbix.com/ap/plugin-support/zbxerr"
+)
+
+const parametersChangesStateKey = "parameters.changes"
+
+// 多值参数（如control_files）在v$spparameter中按ordinal分多行存放，合并后与v$parameter比较
+// 同时存在'*'和实例级设置时以实例级为准
+const spparameterSubquery = `
+SELECT name, MAX(value) KEEP (DENSE_RANK FIRST ORDER BY DECODE(sid, '*', 1, 0)) AS value
+  FROM (SELECT sid, name, LISTAGG(display_value, ', ') WITHIN GROUP (ORDER BY ordinal) AS value
+          FROM v$spparameter
+         WHERE isspecified = 'TRUE'
+           AND sid IN ('*', SYS_CONTEXT('USERENV', 'INSTANCE_NAME'))
+         GROUP BY sid, name)
+ GROUP BY name`
+
+const parametersQuery = `
+SELECT p.name, p.display_value, sp.value, p.isdefault, p.ismodified
+  FROM v$parameter p
+  LEFT JOIN (` + spparameterSubquery + `) sp ON sp.name = p.name`
+
+const parametersSnapshotQuery = `SELECT name, display_value FROM v$parameter`
+
+const parametersMismatchQuery = `
+SELECT p.name, p.display_value, sp.value
+  FROM v$parameter p
+  JOIN (` + spparameterSubquery + `) sp ON sp.name = p.name
+ WHERE UPPER(NVL(p.display_value, '-')) <> UPPER(NVL(sp.value, '-'))`
+
+type parameter struct {
+	Value       *string `json:"value"`
+	SpfileValue *string `json:"spfile_value"`
+	IsDefault   bool    `json:"is_default"`
+	IsModified  string  `json:"is_modified"`
+}
+
+type parameterChange struct {
+	Name     string  `json:"name"`
+	OldValue *string `json:"old_value"`
+	NewValue *string `json:"new_value"`
+}
+
+type parameterMismatch struct {
+	Name        string  `json:"name"`
+	Value       *string `json:"value"`
+	SpfileValue *string `json:"spfile_value"`
+}
+
+type parametersChanges struct {
+	Count          int                 `json:"count"`
+	Changed        []parameterChange   `json:"changed"`
+	SpfileMismatch []parameterMismatch `json:"spfile_mismatch"`
+}
+
+// ParametersHandler 返回指定参数的当前值和spfile中的值，未指定参数名时返回全部参数
+func ParametersHandler(ctx context.Context, s Database, _ map[string]string, names ...string) (interface{}, error) {
+	query := parametersQuery
+	args := make([]interface{}, 0, len(names))
+
+	if len(names) > 0 {
+		binds := make([]string, len(names))
+
+		for i, name := range names {
+			binds[i] = ":" + strconv.Itoa(i+1)
+			args = append(args, strings.ToLower(strings.TrimSpace(name)))
+		}
+
+		query += fmt.Sprintf("\n WHERE p.name IN (%s)", strings.Join(binds, ", "))
+	}
+
+	rows, err := s.Query(ctx, query, args...)
+	if err != nil {
+		return nil, zbxerr.ErrorCannotFetchData.Wrap(err)
+	}
+	defer rows.Close()
+
+	res := make(map[string]parameter)
+
+	for rows.Next() {
+		var (
+			name, isDefault, isModified string
+			value, spfileValue          sql.NullString
+		)
+
+		if err = rows.Scan(&name, &value, &spfileValue, &isDefault, &isModified); err != nil {
+			return nil, zbxerr.ErrorCannotFetchData.Wrap(err)
+		}
+
+		res[name] = parameter{
+			Value:       nullStringPtr(value),
+			SpfileValue: nullStringPtr(spfileValue),
+			IsDefault:   isDefault == "TRUE",
+			IsModified:  isModified,
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, zbxerr.ErrorCannotFetchData.Wrap(err)
+	}
+
+	jsonRes, err := json.Marshal(res)
+	if err != nil {
+		return nil, zbxerr.ErrorCannotMarshalJSON.Wrap(err)
+	}
+
+	return string(jsonRes), nil
+}
+
+// ParametersChangesHandler 返回上次轮询以来值发生变化的参数，以及内存值与spfile值不一致的参数
+// 首次轮询只记录基线，changed为空
+func ParametersChangesHandler(ctx context.Context, s Database, _ map[string]string, _ ...string) (interface{}, error) {
+	res := parametersChanges{
+		Changed:        make([]parameterChange, 0),
+		SpfileMismatch: make([]parameterMismatch, 0),
+	}
+
+	err := s.State().Do(parametersChangesStateKey, func(prev interface{}) (interface{}, error) {
+		cur, err := getParametersSnapshot(ctx, s)
+		if err != nil {
+			return nil, err
+		}
+
+		if last, ok := prev.(map[string]*string); ok {
+			for name, value := range cur {
+				old, found := last[name]
+				if found && !equalStringPtr(old, value) {
+					res.Changed = append(res.Changed, parameterChange{Name: name, OldValue: old, NewValue: value})
+				}
+			}
+		}
+
+		return cur, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err = getParametersMismatch(ctx, s, &res); err != nil {
+		return nil, err
+	}
+
+	res.Count = len(res.Changed) + len(res.SpfileMismatch)
+
+	jsonRes, err := json.Marshal(res)
+	if err != nil {
+		return nil, zbxerr.ErrorCannotMarshalJSON.Wrap(err)
+	}
+
+	return string(jsonRes), nil
+}
+
+func getParametersSnapshot(ctx context.Context, s Database) (map[string]*string, error) {
+	rows, err := s.Query(ctx, parametersSnapshotQuery)
+	if err != nil {
+		return nil, zbxerr.ErrorCannotFetchData.Wrap(err)
+	}
+	defer rows.Close()
+
+	snap := make(map[string]*string)
+
+	for rows.Next() {
+		var (
+			name  string
+			value sql.NullString
+		)
+
+		if err = rows.Scan(&name, &value); err != nil {
+			return nil, zbxerr.ErrorCannotFetchData.Wrap(err)
+		}
+
+		snap[name] = nullStringPtr(value)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, zbxerr.ErrorCannotFetchData.Wrap(err)
+	}
+
+	return snap, nil
+}
+
+func getParametersMismatch(ctx context.Context, s Database, res *parametersChanges) error {
+	rows, err := s.Query(ctx, parametersMismatchQuery)
+	if err != nil {
+		return zbxerr.ErrorCannotFetchData.Wrap(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			m                  parameterMismatch
+			value, spfileValue sql.NullString
+		)
+
+		if err = rows.Scan(&m.Name, &value, &spfileValue); err != nil {
+			return zbxerr.ErrorCannotFetchData.Wrap(err)
+		}
+
+		m.Value = nullStringPtr(value)
+		m.SpfileValue = nullStringPtr(spfileValue)
+
+		res.SpfileMismatch = append(res.SpfileMismatch, m)
+	}
+
+	if err = rows.Err(); err != nil {
+		return zbxerr.ErrorCannotFetchData.Wrap(err)
+	}
+
+	return nil
+}
+
+func equalStringPtr(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return *a == *b
+}
@@ -2,12 +2,13 @@ package handlers
 
 import "context"
 
-func PingHandler(ctx context.Context, s Database, _ map[string]string) (interface{}, error) {
-	/*if err := s.Database.Ping(); err != nil {
+// PingHandler 检查数据库是否可用，失败时返回PingFailed而不是错误
+func PingHandler(ctx context.Context, s Database, _ map[string]string, _ ...string) (interface{}, error) {
+	if err := s.Ping(ctx); err != nil {
 		Logger.Debugf("ping failed, %s", err.Error())
 
 		return PingFailed, nil
-	}*/
+	}
 
 	return PingOk, nil
 }
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strconv"
+
+	"git.zabbix.com/ap/plugin-support/zbxerr"
+)
+
+const racInterconnectStateKey = "rac.interconnect"
+
+const racInterconnectQuery = `
+SELECT inst_id, name, value
+  FROM gv$sysstat
+ WHERE name IN ('gc cr blocks received', 'gc cr block receive time',
+                'gc current blocks received', 'gc current block receive time',
+                'gc cr blocks served', 'gc current blocks served', 'gc blocks lost')`
+
+type racInterconnect struct {
+	CRBlocksReceived      int64    `json:"cr_blocks_received"`
+	CurrentBlocksReceived int64    `json:"current_blocks_received"`
+	CRBlocksServed        int64    `json:"cr_blocks_served"`
+	CurrentBlocksServed   int64    `json:"current_blocks_served"`
+	BlocksLost            int64    `json:"blocks_lost"`
+	AvgCRReceiveTime      *float64 `json:"avg_cr_receive_time"`
+	AvgCurrentReceiveTime *float64 `json:"avg_current_receive_time"`
+}
+
+// RACInterconnectHandler 返回各实例的global cache累计块数，以及两次轮询之间的平均块接收时间（毫秒）
+// 首次轮询或区间内没有接收块时平均时间为null
+func RACInterconnectHandler(ctx context.Context, s Database, _ map[string]string, _ ...string) (interface{}, error) {
+	res := make(map[string]racInterconnect)
+
+	err := s.State().Do(racInterconnectStateKey, func(prev interface{}) (interface{}, error) {
+		cur, err := getRACInterconnectStats(ctx, s)
+		if err != nil {
+			return nil, err
+		}
+
+		last, _ := prev.(map[string]map[string]int64)
+
+		for inst, stats := range cur {
+			ic := racInterconnect{
+				CRBlocksReceived:      stats["gc cr blocks received"],
+				CurrentBlocksReceived: stats["gc current blocks received"],
+				CRBlocksServed:        stats["gc cr blocks served"],
+				CurrentBlocksServed:   stats["gc current blocks served"],
+				BlocksLost:            stats["gc blocks lost"],
+			}
+
+			if p, ok := last[inst]; ok {
+				ic.AvgCRReceiveTime = avgReceiveTime(p, stats, "gc cr block receive time", "gc cr blocks received")
+				ic.AvgCurrentReceiveTime = avgReceiveTime(p, stats, "gc current block receive time",
+					"gc current blocks received")
+			}
+
+			res[inst] = ic
+		}
+
+		return cur, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	jsonRes, err := json.Marshal(res)
+	if err != nil {
+		return nil, zbxerr.ErrorCannotMarshalJSON.Wrap(err)
+	}
+
+	return string(jsonRes), nil
+}
+
+func getRACInterconnectStats(ctx context.Context, s Database) (map[string]map[string]int64, error) {
+	rows, err := s.Query(ctx, racInterconnectQuery)
+	if err != nil {
+		return nil, zbxerr.ErrorCannotFetchData.Wrap(err)
+	}
+	defer rows.Close()
+
+	stats := make(map[string]map[string]int64)
+
+	for rows.Next() {
+		var (
+			instID int64
+			name   string
+			value  sql.NullInt64
+		)
+
+		if err = rows.Scan(&instID, &name, &value); err != nil {
+			return nil, zbxerr.ErrorCannotFetchData.Wrap(err)
+		}
+
+		inst := strconv.FormatInt(instID, 10)
+
+		if _, ok := stats[inst]; !ok {
+			stats[inst] = make(map[string]int64)
+		}
+
+		stats[inst][name] = value.Int64
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, zbxerr.ErrorCannotFetchData.Wrap(err)
+	}
+
+	return stats, nil
+}
+
+// avgReceiveTime 接收时间单位为厘秒，返回区间内每块的平均接收时间（毫秒）
+func avgReceiveTime(prev, cur map[string]int64, timeStat, blocksStat string) *float64 {
+	blocks := cur[blocksStat] - prev[blocksStat]
+	if blocks <= 0 {
+		return nil
+	}
+
+	avg := float64(cur[timeStat]-prev[timeStat]) * 10 / float64(blocks)
+
+	return &avg
+}
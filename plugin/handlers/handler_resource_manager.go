@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"git.zabbix.com/ap/plugin-support/zbxerr"
+)
+
+// 12.1起CDB中每个容器都有各自的资源计划和消费者组，只统计当前容器
+var resourcePlanQuery = NewVersionedQuery("resource plan").
+	Since("11.2", `
+SELECT MAX(name)
+  FROM v$rsrc_plan
+ WHERE is_top_plan = 'TRUE'`).
+	Since("12.1", `
+SELECT MAX(name)
+  FROM v$rsrc_plan
+ WHERE is_top_plan = 'TRUE'
+   AND con_id = SYS_CONTEXT('USERENV', 'CON_ID')`)
+
+// v$rsrc_consumer_group中为自实例启动以来的累计值，v$rsrcmgrmetric中为最近一分钟的统计
+// 时间单位均为毫秒，io_service_waits为因I/O资源管理而等待的次数
+const consumerGroupsQueryTmpl = `
+SELECT g.name,
+       g.active_sessions,
+       g.queue_length,
+       g.consumed_cpu_time,
+       g.cpu_wait_time,
+       g.cpu_waits,
+       NVL(g.io_service_waits, 0),
+       NVL(g.io_service_time, 0),
+       NVL(m.cpu_consumed_time, 0),
+       NVL(m.cpu_wait_time, 0),
+       NVL(m.avg_running_sessions, 0),
+       NVL(m.avg_waiting_sessions, 0),
+       NVL(m.io_requests, 0),
+       NVL(m.io_megabytes, 0)
+  FROM v$rsrc_consumer_group g
+  LEFT JOIN v$rsrcmgrmetric m ON m.consumer_group_name = g.name%s`
+
+var consumerGroupsQuery = NewVersionedQuery("consumer groups").
+	Since("11.2", fmt.Sprintf(consumerGroupsQueryTmpl, "")).
+	Since("12.1", fmt.Sprintf(consumerGroupsQueryTmpl, `
+   AND m.con_id = g.con_id
+ WHERE g.con_id = SYS_CONTEXT('USERENV', 'CON_ID')`))
+
+type consumerGroup struct {
+	ActiveSessions int64               `json:"active_sessions"`
+	QueuedSessions int64               `json:"queued_sessions"`
+	CPUConsumed    int64               `json:"cpu_consumed"`
+	CPUWaitTime    int64               `json:"cpu_wait_time"`
+	CPUWaits       int64               `json:"cpu_waits"`
+	IOServiceWaits int64               `json:"io_service_waits"`
+	IOServiceTime  int64               `json:"io_service_time"`
+	LastMinute     consumerGroupMinute `json:"last_minute"`
+}
+
+type consumerGroupMinute struct {
+	CPUConsumed        int64   `json:"cpu_consumed"`
+	CPUWaitTime        int64   `json:"cpu_wait_time"`
+	AvgRunningSessions float64 `json:"avg_running_sessions"`
+	AvgWaitingSessions float64 `json:"avg_waiting_sessions"`
+	IORequests         int64   `json:"io_requests"`
+	IOMegabytes        int64   `json:"io_megabytes"`
+}
+
+type resourceManager struct {
+	Plan       string                   `json:"plan"`
+	Throttling bool                     `json:"throttling"`
+	Groups     map[string]consumerGroup `json:"groups"`
+}
+
+// ResourceManagerHandler 返回当前生效的资源计划和各消费者组的会话、CPU与I/O统计
+// 最近一分钟内有会话排队或等待CPU时throttling为true
+func ResourceManagerHandler(ctx context.Context, s Database, _ map[string]string, _ ...string) (interface{}, error) {
+	var (
+		res  = resourceManager{Groups: make(map[string]consumerGroup)}
+		plan sql.NullString
+	)
+
+	query, err := resourcePlanQuery.For(s.Version())
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.QueryRow(ctx, query).Scan(&plan); err != nil {
+		return nil, zbxerr.ErrorCannotFetchData.Wrap(err)
+	}
+
+	res.Plan = plan.String
+
+	query, err = consumerGroupsQuery.For(s.Version())
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.Query(ctx, query)
+	if err != nil {
+		return nil, zbxerr.ErrorCannotFetchData.Wrap(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			name string
+			g    consumerGroup
+		)
+
+		err = rows.Scan(&name, &g.ActiveSessions, &g.QueuedSessions, &g.CPUConsumed, &g.CPUWaitTime, &g.CPUWaits,
+			&g.IOServiceWaits, &g.IOServiceTime, &g.LastMinute.CPUConsumed, &g.LastMinute.CPUWaitTime,
+			&g.LastMinute.AvgRunningSessions, &g.LastMinute.AvgWaitingSessions, &g.LastMinute.IORequests,
+			&g.LastMinute.IOMegabytes)
+		if err != nil {
+			return nil, zbxerr.ErrorCannotFetchData.Wrap(err)
+		}
+
+		if g.QueuedSessions > 0 || g.LastMinute.CPUWaitTime > 0 {
+			res.Throttling = true
+		}
+
+		res.Groups[name] = g
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, zbxerr.ErrorCannotFetchData.Wrap(err)
+	}
+
+	jsonRes, err := json.Marshal(res)
+	if err != nil {
+		return nil, zbxerr.ErrorCannotMarshalJSON.Wrap(err)
+	}
+
+	return string(jsonRes), nil
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"git.zabbix.com/ap/plugin-support/zbxerr"
+)
+
+const sessionsQuery = `
+SELECT %[1]s,
+       COUNT(*),
+       SUM(DECODE(status, 'ACTIVE', 1, 0)),
+       SUM(DECODE(status, 'INACTIVE', 1, 0)),
+       SUM(DECODE(type, 'USER', 1, 0)),
+       SUM(DECODE(type, 'BACKGROUND', 1, 0)),
+       SUM(CASE WHEN blocking_session IS NOT NULL THEN 1 ELSE 0 END)
+  FROM %[2]s
+ GROUP BY %[1]s`
+
+type sessionsStats struct {
+	Total      int64 `json:"total"`
+	Active     int64 `json:"active"`
+	Inactive   int64 `json:"inactive"`
+	User       int64 `json:"user"`
+	Background int64 `json:"background"`
+	Blocked    int64 `json:"blocked"`
+}
+
+func SessionsStatsHandler(ctx context.Context, s Database, params map[string]string, _ ...string) (interface{}, error) {
+	mode := params["Mode"]
+	instCol, from := clusterView(mode, "session")
+
+	rows, err := s.Query(ctx, fmt.Sprintf(sessionsQuery, instCol, from))
+	if err != nil {
+		return nil, zbxerr.ErrorCannotFetchData.Wrap(err)
+	}
+	defer rows.Close()
+
+	res := make(map[string]interface{})
+
+	for rows.Next() {
+		var (
+			instID int64
+			stats  sessionsStats
+		)
+
+		err = rows.Scan(&instID, &stats.Total, &stats.Active, &stats.Inactive, &stats.User, &stats.Background,
+			&stats.Blocked)
+		if err != nil {
+			return nil, zbxerr.ErrorCannotFetchData.Wrap(err)
+		}
+
+		res[strconv.FormatInt(instID, 10)] = stats
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, zbxerr.ErrorCannotFetchData.Wrap(err)
+	}
+
+	jsonRes, err := json.Marshal(byInstance(mode, res))
+	if err != nil {
+		return nil, zbxerr.ErrorCannotMarshalJSON.Wrap(err)
+	}
+
+	return string(jsonRes), nil
+}
@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"git.zabbix.com/ap/plugin-support/zbxerr"
+)
+
+const (
+	SQLTopElapsed    = "elapsed"
+	SQLTopCPU        = "cpu"
+	SQLTopBufferGets = "buffer_gets"
+)
+
+const sqlTopStateKey = "sql.top"
+
+// 12.1起CDB中同一语句在各容器中分别统计，快照需按con_id区分
+var sqlStatsQuery = NewVersionedQuery("sql stats").
+	Since("11.2", `
+SELECT sql_id, plan_hash_value, 0, executions, elapsed_time, cpu_time, buffer_gets
+  FROM v$sqlstats`).
+	Since("12.1", `
+SELECT sql_id, plan_hash_value, con_id, executions, elapsed_time, cpu_time, buffer_gets
+  FROM v$sqlstats`)
+
+// 模块名和SQL文本在确定前N条语句后再单独查询，避免每次轮询读取全部SQL文本
+const sqlDetailsQuery = `
+SELECT sql_id, MAX(module), MAX(SUBSTR(sql_text, 1, 200))
+  FROM v$sql
+ WHERE sql_id IN (%s)
+ GROUP BY sql_id`
+
+type sqlStatsKey struct {
+	sqlID    string
+	planHash int64
+	conID    int64
+}
+
+type sqlStatsSample struct {
+	executions int64
+	elapsed    int64
+	cpu        int64
+	bufferGets int64
+}
+
+type sqlStatsSnapshot struct {
+	ts    time.Time
+	stats map[sqlStatsKey]sqlStatsSample
+}
+
+type sqlTopEntry struct {
+	SQLID             string  `json:"sql_id"`
+	PlanHashValue     int64   `json:"plan_hash_value"`
+	ConID             int64   `json:"con_id"`
+	Executions        int64   `json:"executions"`
+	ElapsedTime       float64 `json:"elapsed_time"`
+	ElapsedPerExec    float64 `json:"elapsed_per_exec"`
+	CPUTime           float64 `json:"cpu_time"`
+	CPUPerExec        float64 `json:"cpu_per_exec"`
+	BufferGets        int64   `json:"buffer_gets"`
+	BufferGetsPerExec float64 `json:"buffer_gets_per_exec"`
+	Module            string  `json:"module"`
+	SQLText           string  `json:"sql_text"`
+
+	sort int64
+}
+
+type sqlTop struct {
+	Interval   float64       `json:"interval"`
+	Statements []sqlTopEntry `json:"statements"`
+}
+
+// SQLTopHandler 返回两次轮询之间按指定维度排序的前N条SQL语句，时间单位为秒
+// 首次轮询只记录基线，返回空列表
+func SQLTopHandler(ctx context.Context, s Database, params map[string]string, _ ...string) (interface{}, error) {
+	metric := params["Metric"]
+
+	n, err := strconv.Atoi(params["N"])
+	if err != nil {
+		return nil, zbxerr.ErrorInvalidParams.Wrap(err)
+	}
+
+	res := sqlTop{Statements: make([]sqlTopEntry, 0)}
+
+	// 不同参数的监控项各自维护快照，增量按各自的轮询间隔计算
+	stateKey := sqlTopStateKey + "|" + metric + "|" + params["N"]
+
+	err = s.State().Do(stateKey, func(prev interface{}) (interface{}, error) {
+		cur, err := getSQLStatsSnapshot(ctx, s)
+		if err != nil {
+			return nil, err
+		}
+
+		if last, ok := prev.(*sqlStatsSnapshot); ok {
+			res.Interval = cur.ts.Sub(last.ts).Seconds()
+			res.Statements = topSQLDeltas(last, cur, metric, n)
+		}
+
+		return cur, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err = getSQLDetails(ctx, s, res.Statements); err != nil {
+		return nil, err
+	}
+
+	jsonRes, err := json.Marshal(res)
+	if err != nil {
+		return nil, zbxerr.ErrorCannotMarshalJSON.Wrap(err)
+	}
+
+	return string(jsonRes), nil
+}
+
+func getSQLStatsSnapshot(ctx context.Context, s Database) (*sqlStatsSnapshot, error) {
+	query, err := sqlStatsQuery.For(s.Version())
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.Query(ctx, query)
+	if err != nil {
+		return nil, zbxerr.ErrorCannotFetchData.Wrap(err)
+	}
+	defer rows.Close()
+
+	snap := &sqlStatsSnapshot{ts: time.Now(), stats: make(map[sqlStatsKey]sqlStatsSample)}
+
+	for rows.Next() {
+		var (
+			key    sqlStatsKey
+			sample sqlStatsSample
+		)
+
+		err = rows.Scan(&key.sqlID, &key.planHash, &key.conID, &sample.executions, &sample.elapsed, &sample.cpu,
+			&sample.bufferGets)
+		if err != nil {
+			return nil, zbxerr.ErrorCannotFetchData.Wrap(err)
+		}
+
+		snap.stats[key] = sample
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, zbxerr.ErrorCannotFetchData.Wrap(err)
+	}
+
+	return snap, nil
+}
+
+// topSQLDeltas 计算两次快照之间的增量并返回前n条
+// 语句被换出共享池后重新加载时累计值会变小，此时以当前值作为增量
+func topSQLDeltas(last, cur *sqlStatsSnapshot, metric string, n int) []sqlTopEntry {
+	entries := make([]sqlTopEntry, 0)
+
+	for key, c := range cur.stats {
+		d := c
+
+		if p, ok := last.stats[key]; ok && c.executions >= p.executions && c.elapsed >= p.elapsed {
+			d = sqlStatsSample{
+				executions: c.executions - p.executions,
+				elapsed:    c.elapsed - p.elapsed,
+				cpu:        c.cpu - p.cpu,
+				bufferGets: c.bufferGets - p.bufferGets,
+			}
+		}
+
+		if d.executions == 0 && d.elapsed == 0 {
+			continue
+		}
+
+		e := sqlTopEntry{
+			SQLID:         key.sqlID,
+			PlanHashValue: key.planHash,
+			ConID:         key.conID,
+			Executions:    d.executions,
+			ElapsedTime:   float64(d.elapsed) / 1e6,
+			CPUTime:       float64(d.cpu) / 1e6,
+			BufferGets:    d.bufferGets,
+		}
+
+		if d.executions > 0 {
+			e.ElapsedPerExec = e.ElapsedTime / float64(d.executions)
+			e.CPUPerExec = e.CPUTime / float64(d.executions)
+			e.BufferGetsPerExec = float64(d.bufferGets) / float64(d.executions)
+		}
+
+		switch metric {
+		case SQLTopCPU:
+			e.sort = d.cpu
+		case SQLTopBufferGets:
+			e.sort = d.bufferGets
+		default:
+			e.sort = d.elapsed
+		}
+
+		entries = append(entries, e)
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].sort > entries[j].sort })
+
+	if len(entries) > n {
+		entries = entries[:n]
+	}
+
+	return entries
+}
+
+func getSQLDetails(ctx context.Context, s Database, entries []sqlTopEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	binds := make([]string, len(entries))
+	args := make([]interface{}, len(entries))
+
+	for i := range entries {
+		binds[i] = ":" + strconv.Itoa(i+1)
+		args[i] = entries[i].SQLID
+	}
+
+	rows, err := s.Query(ctx, fmt.Sprintf(sqlDetailsQuery, strings.Join(binds, ", ")), args...)
+	if err != nil {
+		return zbxerr.ErrorCannotFetchData.Wrap(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			sqlID        string
+			module, text sql.NullString
+		)
+
+		if err = rows.Scan(&sqlID, &module, &text); err != nil {
+			return zbxerr.ErrorCannotFetchData.Wrap(err)
+		}
+
+		for i := range entries {
+			if entries[i].SQLID == sqlID {
+				entries[i].Module = module.String
+				entries[i].SQLText = text.String
+			}
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return zbxerr.ErrorCannotFetchData.Wrap(err)
+	}
+
+	return nil
+}
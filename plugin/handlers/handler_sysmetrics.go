@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"git.zabbix.com/ap/plugin-support/zbxerr"
+)
+
+// group_id = 2 为60秒间隔的系统指标
+const sysmetricsQuery = `
+SELECT %[1]s, metric_name, value
+  FROM %[2]s
+ WHERE group_id = 2`
+
+func SysmetricsHandler(ctx context.Context, s Database, params map[string]string, _ ...string) (interface{}, error) {
+	mode := params["Mode"]
+	instCol, from := clusterView(mode, "sysmetric")
+
+	rows, err := s.Query(ctx, fmt.Sprintf(sysmetricsQuery, instCol, from))
+	if err != nil {
+		return nil, zbxerr.ErrorCannotFetchData.Wrap(err)
+	}
+	defer rows.Close()
+
+	res := make(map[string]interface{})
+
+	for rows.Next() {
+		var (
+			instID int64
+			name   string
+			value  float64
+		)
+
+		if err = rows.Scan(&instID, &name, &value); err != nil {
+			return nil, zbxerr.ErrorCannotFetchData.Wrap(err)
+		}
+
+		inst := strconv.FormatInt(instID, 10)
+
+		metrics, ok := res[inst].(map[string]float64)
+		if !ok {
+			metrics = make(map[string]float64)
+			res[inst] = metrics
+		}
+
+		metrics[name] = value
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, zbxerr.ErrorCannotFetchData.Wrap(err)
+	}
+
+	jsonRes, err := json.Marshal(byInstance(mode, res))
+	if err != nil {
+		return nil, zbxerr.ErrorCannotMarshalJSON.Wrap(err)
+	}
+
+	return string(jsonRes), nil
+}
@@ -3,18 +3,145 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"time"
 
 	"git.zabbix.com/ap/plugin-support/zbxerr"
-	"go.mongodb.org/mongo-driver/bson"
 )
 
-func TablespacesUsageHandler(ctx context.Context, s Database, _ map[string]string) (interface{}, error) {
-	colUsage := &bson.M{}
+const (
+	tablespacesHistoryStateKey = "tablespaces.history"
 
-	jsonRes, err := json.Marshal(colUsage)
+	// 增长历史保留7天，每小时最多保留一个采样点
+	tablespacesHistoryWindow = 7 * 24 * time.Hour
+	tablespacesHistoryStep   = time.Hour
+)
+
+// max_size按自动扩展上限计算，未开启自动扩展的文件以当前大小计
+const tablespacesUsageQuery = `
+SELECT df.tablespace_name,
+       t.contents,
+       df.total,
+       df.max_total,
+       df.total - NVL(fs.free, 0)
+  FROM (SELECT tablespace_name,
+               SUM(bytes) AS total,
+               SUM(GREATEST(DECODE(autoextensible, 'YES', maxbytes, bytes), bytes)) AS max_total
+          FROM dba_data_files
+         GROUP BY tablespace_name) df
+  JOIN dba_tablespaces t ON t.tablespace_name = df.tablespace_name
+  LEFT JOIN (SELECT tablespace_name, SUM(bytes) AS free
+               FROM dba_free_space
+              GROUP BY tablespace_name) fs ON fs.tablespace_name = df.tablespace_name`
+
+type tablespaceSample struct {
+	ts   time.Time
+	used int64
+}
+
+type tablespaceUsage struct {
+	Contents      string   `json:"contents"`
+	Size          int64    `json:"size"`
+	MaxSize       int64    `json:"max_size"`
+	Used          int64    `json:"used"`
+	Free          int64    `json:"free"`
+	PUsed         float64  `json:"pused"`
+	BytesPerDay   *float64 `json:"bytes_per_day"`
+	DaysUntilFull *float64 `json:"days_until_full"`
+}
+
+// TablespacesUsageHandler 返回各表空间的使用情况，并根据插件保存的历史用量估算每日增长量和距离写满自动扩展上限的天数
+// 历史不足一个采样间隔时增长估算为null
+func TablespacesUsageHandler(ctx context.Context, s Database, _ map[string]string, _ ...string) (interface{}, error) {
+	res := make(map[string]tablespaceUsage)
+
+	err := s.State().Do(tablespacesHistoryStateKey, func(prev interface{}) (interface{}, error) {
+		rows, err := s.Query(ctx, tablespacesUsageQuery)
+		if err != nil {
+			return nil, zbxerr.ErrorCannotFetchData.Wrap(err)
+		}
+		defer rows.Close()
+
+		now := time.Now()
+		last, _ := prev.(map[string][]tablespaceSample)
+		history := make(map[string][]tablespaceSample)
+
+		for rows.Next() {
+			var (
+				name string
+				ts   tablespaceUsage
+			)
+
+			if err = rows.Scan(&name, &ts.Contents, &ts.Size, &ts.MaxSize, &ts.Used); err != nil {
+				return nil, zbxerr.ErrorCannotFetchData.Wrap(err)
+			}
+
+			ts.Free = ts.MaxSize - ts.Used
+
+			if ts.MaxSize > 0 {
+				ts.PUsed = float64(ts.Used) / float64(ts.MaxSize) * 100
+			}
+
+			history[name] = appendTablespaceSample(last[name], tablespaceSample{ts: now, used: ts.Used})
+			setTablespaceGrowth(&ts, history[name], now)
+
+			res[name] = ts
+		}
+
+		if err = rows.Err(); err != nil {
+			return nil, zbxerr.ErrorCannotFetchData.Wrap(err)
+		}
+
+		return history, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	jsonRes, err := json.Marshal(res)
 	if err != nil {
 		return nil, zbxerr.ErrorCannotMarshalJSON.Wrap(err)
 	}
 
 	return string(jsonRes), nil
 }
+
+// appendTablespaceSample 丢弃超出保留窗口的采样，距上一个保留点不足一个间隔时只更新最新采样
+func appendTablespaceSample(samples []tablespaceSample, cur tablespaceSample) []tablespaceSample {
+	out := make([]tablespaceSample, 0, len(samples)+1)
+
+	for _, sample := range samples {
+		if cur.ts.Sub(sample.ts) <= tablespacesHistoryWindow {
+			out = append(out, sample)
+		}
+	}
+
+	if n := len(out); n > 1 && cur.ts.Sub(out[n-2].ts) < tablespacesHistoryStep {
+		out[n-1] = cur
+
+		return out
+	}
+
+	return append(out, cur)
+}
+
+// setTablespaceGrowth 以历史中最早的采样和当前采样之间的用量变化估算增长速度
+func setTablespaceGrowth(ts *tablespaceUsage, samples []tablespaceSample, now time.Time) {
+	if len(samples) < 2 {
+		return
+	}
+
+	oldest := samples[0]
+
+	days := now.Sub(oldest.ts).Hours() / 24
+	if days < tablespacesHistoryStep.Hours()/24 {
+		return
+	}
+
+	perDay := float64(ts.Used-oldest.used) / days
+	ts.BytesPerDay = &perDay
+
+	if perDay > 0 {
+		untilFull := float64(ts.Free) / perDay
+		ts.DaysUntilFull = &untilFull
+	}
+}
@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strconv"
+
+	"git.zabbix.com/ap/plugin-support/zbxerr"
+)
+
+// v$temp_space_header反映临时文件中已分配的空间，v$sort_segment反映当前实际被会话占用的空间
+const tempSpaceQuery = `
+SELECT h.tablespace_name,
+       h.allocated + h.free,
+       h.allocated,
+       NVL(ss.used_blocks, 0) * t.block_size
+  FROM (SELECT tablespace_name, SUM(bytes_used) AS allocated, SUM(bytes_free) AS free
+          FROM v$temp_space_header
+         GROUP BY tablespace_name) h
+  JOIN dba_tablespaces t ON t.tablespace_name = h.tablespace_name
+  LEFT JOIN v$sort_segment ss ON ss.tablespace_name = h.tablespace_name`
+
+const tempTopSessionsQuery = `
+SELECT *
+  FROM (SELECT s.sid,
+               s.serial#,
+               s.username,
+               u.sql_id,
+               u.segtype,
+               u.tablespace,
+               SUM(u.blocks * t.block_size) / 1048576 AS mb
+          FROM v$tempseg_usage u
+          JOIN v$session s ON s.saddr = u.session_addr
+          JOIN dba_tablespaces t ON t.tablespace_name = u.tablespace
+         GROUP BY s.sid, s.serial#, s.username, u.sql_id, u.segtype, u.tablespace
+         ORDER BY mb DESC)
+ WHERE ROWNUM <= :1`
+
+type tempTablespace struct {
+	Size      int64   `json:"size"`
+	Allocated int64   `json:"allocated"`
+	Used      int64   `json:"used"`
+	Free      int64   `json:"free"`
+	PUsed     float64 `json:"pused"`
+}
+
+type tempSession struct {
+	SID        int64   `json:"sid"`
+	Serial     int64   `json:"serial"`
+	Username   string  `json:"username"`
+	SQLID      string  `json:"sql_id"`
+	SegType    string  `json:"segtype"`
+	Tablespace string  `json:"tablespace"`
+	MB         float64 `json:"mb"`
+}
+
+type tempUsage struct {
+	Tablespaces map[string]tempTablespace `json:"tablespaces"`
+	TopSessions []tempSession             `json:"top_sessions"`
+}
+
+// TempUsageHandler 返回临时表空间的使用情况和占用临时空间最多的前N个会话
+func TempUsageHandler(ctx context.Context, s Database, params map[string]string, _ ...string) (interface{}, error) {
+	n, err := strconv.Atoi(params["N"])
+	if err != nil {
+		return nil, zbxerr.ErrorInvalidParams.Wrap(err)
+	}
+
+	res := tempUsage{
+		Tablespaces: make(map[string]tempTablespace),
+		TopSessions: make([]tempSession, 0),
+	}
+
+	if err = getTempSpace(ctx, s, &res); err != nil {
+		return nil, err
+	}
+
+	if err = getTempTopSessions(ctx, s, n, &res); err != nil {
+		return nil, err
+	}
+
+	jsonRes, err := json.Marshal(res)
+	if err != nil {
+		return nil, zbxerr.ErrorCannotMarshalJSON.Wrap(err)
+	}
+
+	return string(jsonRes), nil
+}
+
+func getTempSpace(ctx context.Context, s Database, res *tempUsage) error {
+	rows, err := s.Query(ctx, tempSpaceQuery)
+	if err != nil {
+		return zbxerr.ErrorCannotFetchData.Wrap(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			name string
+			ts   tempTablespace
+		)
+
+		if err = rows.Scan(&name, &ts.Size, &ts.Allocated, &ts.Used); err != nil {
+			return zbxerr.ErrorCannotFetchData.Wrap(err)
+		}
+
+		ts.Free = ts.Size - ts.Used
+
+		if ts.Size > 0 {
+			ts.PUsed = float64(ts.Used) / float64(ts.Size) * 100
+		}
+
+		res.Tablespaces[name] = ts
+	}
+
+	if err = rows.Err(); err != nil {
+		return zbxerr.ErrorCannotFetchData.Wrap(err)
+	}
+
+	return nil
+}
+
+func getTempTopSessions(ctx context.Context, s Database, n int, res *tempUsage) error {
+	rows, err := s.Query(ctx, tempTopSessionsQuery, n)
+	if err != nil {
+		return zbxerr.ErrorCannotFetchData.Wrap(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			sess                            tempSession
+			username, sqlID, segType, space sql.NullString
+		)
+
+		if err = rows.Scan(&sess.SID, &sess.Serial, &username, &sqlID, &segType, &space, &sess.MB); err != nil {
+			return zbxerr.ErrorCannotFetchData.Wrap(err)
+		}
+
+		sess.Username = username.String
+		sess.SQLID = sqlID.String
+		sess.SegType = segType.String
+		sess.Tablespace = space.String
+
+		res.TopSessions = append(res.TopSessions, sess)
+	}
+
+	if err = rows.Err(); err != nil {
+		return zbxerr.ErrorCannotFetchData.Wrap(err)
+	}
+
+	return nil
+}
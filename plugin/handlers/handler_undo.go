@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"git.zabbix.com/ap/plugin-support/zbxerr"
+)
+
+const undoParamsQuery = `
+SELECT MAX(DECODE(name, 'undo_tablespace', value)),
+       NVL(MAX(DECODE(name, 'undo_retention', TO_NUMBER(value))), 0)
+  FROM v$parameter
+ WHERE name IN ('undo_tablespace', 'undo_retention')`
+
+const undoSizeQuery = `
+SELECT NVL(SUM(bytes), 0),
+       NVL(SUM(GREATEST(maxbytes, bytes)), 0)
+  FROM dba_data_files
+ WHERE tablespace_name = :1`
+
+const undoExtentsQuery = `
+SELECT status, SUM(bytes)
+  FROM dba_undo_extents
+ WHERE tablespace_name = :1
+ GROUP BY status`
+
+// 统计最近一小时的v$undostat，tuned_undoretention取最新一个采样周期的值
+const undoStatQuery = `
+SELECT NVL(MAX(tuned_undoretention) KEEP (DENSE_RANK LAST ORDER BY begin_time), 0),
+       NVL(MAX(maxquerylen), 0),
+       NVL(SUM(ssolderrcnt), 0),
+       NVL(SUM(nospaceerrcnt), 0)
+  FROM v$undostat
+ WHERE begin_time > SYSDATE - 1 / 24`
+
+type undoStats struct {
+	Tablespace         string  `json:"tablespace"`
+	Size               int64   `json:"size"`
+	MaxSize            int64   `json:"max_size"`
+	Used               int64   `json:"used"`
+	PUsed              float64 `json:"pused"`
+	Active             int64   `json:"active"`
+	Unexpired          int64   `json:"unexpired"`
+	Expired            int64   `json:"expired"`
+	UndoRetention      int64   `json:"undo_retention"`
+	TunedUndoRetention int64   `json:"tuned_undo_retention"`
+	MaxQueryLength     int64   `json:"max_query_length"`
+	SnapshotTooOld     int64   `json:"snapshot_too_old"`
+	NoSpace            int64   `json:"no_space"`
+}
+
+// UndoStatsHandler 返回当前实例undo表空间的使用情况和最近一小时的undo统计
+// 已使用空间为active和unexpired区的总和，expired区可被随时复用
+func UndoStatsHandler(ctx context.Context, s Database, _ map[string]string, _ ...string) (interface{}, error) {
+	var (
+		res        undoStats
+		tablespace sql.NullString
+	)
+
+	err := s.QueryRow(ctx, undoParamsQuery).Scan(&tablespace, &res.UndoRetention)
+	if err != nil {
+		return nil, zbxerr.ErrorCannotFetchData.Wrap(err)
+	}
+
+	res.Tablespace = tablespace.String
+
+	if res.Tablespace != "" {
+		if err = s.QueryRow(ctx, undoSizeQuery, res.Tablespace).Scan(&res.Size, &res.MaxSize); err != nil {
+			return nil, zbxerr.ErrorCannotFetchData.Wrap(err)
+		}
+
+		if err = getUndoExtents(ctx, s, &res); err != nil {
+			return nil, err
+		}
+	}
+
+	err = s.QueryRow(ctx, undoStatQuery).Scan(&res.TunedUndoRetention, &res.MaxQueryLength, &res.SnapshotTooOld,
+		&res.NoSpace)
+	if err != nil {
+		return nil, zbxerr.ErrorCannotFetchData.Wrap(err)
+	}
+
+	res.Used = res.Active + res.Unexpired
+
+	if res.MaxSize > 0 {
+		res.PUsed = float64(res.Used) / float64(res.MaxSize) * 100
+	}
+
+	jsonRes, err := json.Marshal(res)
+	if err != nil {
+		return nil, zbxerr.ErrorCannotMarshalJSON.Wrap(err)
+	}
+
+	return string(jsonRes), nil
+}
+
+func getUndoExtents(ctx context.Context, s Database, res *undoStats) error {
+	rows, err := s.Query(ctx, undoExtentsQuery, res.Tablespace)
+	if err != nil {
+		return zbxerr.ErrorCannotFetchData.Wrap(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			status string
+			bytes  int64
+		)
+
+		if err = rows.Scan(&status, &bytes); err != nil {
+			return zbxerr.ErrorCannotFetchData.Wrap(err)
+		}
+
+		switch status {
+		case "ACTIVE":
+			res.Active = bytes
+		case "UNEXPIRED":
+			res.Unexpired = bytes
+		case "EXPIRED":
+			res.Expired = bytes
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return zbxerr.ErrorCannotFetchData.Wrap(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"git.zabbix.com/ap/plugin-support/zbxerr"
+)
+
+// time_waited单位为厘秒，转换为秒
+const waitsQuery = `
+SELECT %[1]s, wait_class, total_waits, time_waited / 100
+  FROM %[2]s
+ WHERE wait_class <> 'Idle'`
+
+type waitClassStats struct {
+	TotalWaits int64   `json:"total_waits"`
+	TimeWaited float64 `json:"time_waited"`
+}
+
+func WaitsStatsHandler(ctx context.Context, s Database, params map[string]string, _ ...string) (interface{}, error) {
+	mode := params["Mode"]
+	instCol, from := clusterView(mode, "system_wait_class")
+
+	rows, err := s.Query(ctx, fmt.Sprintf(waitsQuery, instCol, from))
+	if err != nil {
+		return nil, zbxerr.ErrorCannotFetchData.Wrap(err)
+	}
+	defer rows.Close()
+
+	res := make(map[string]interface{})
+
+	for rows.Next() {
+		var (
+			instID    int64
+			waitClass string
+			stats     waitClassStats
+		)
+
+		if err = rows.Scan(&instID, &waitClass, &stats.TotalWaits, &stats.TimeWaited); err != nil {
+			return nil, zbxerr.ErrorCannotFetchData.Wrap(err)
+		}
+
+		inst := strconv.FormatInt(instID, 10)
+
+		classes, ok := res[inst].(map[string]waitClassStats)
+		if !ok {
+			classes = make(map[string]waitClassStats)
+			res[inst] = classes
+		}
+
+		classes[waitClass] = stats
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, zbxerr.ErrorCannotFetchData.Wrap(err)
+	}
+
+	jsonRes, err := json.Marshal(byInstance(mode, res))
+	if err != nil {
+		return nil, zbxerr.ErrorCannotMarshalJSON.Wrap(err)
+	}
+
+	return string(jsonRes), nil
+}
@@ -2,6 +2,8 @@ package handlers
 
 import (
 	"context"
+	"database/sql"
+
 	"git.zabbix.com/ap/plugin-support/log"
 )
 
@@ -15,6 +17,10 @@ var Logger log.Logger
 type Database interface {
 	Database(name string) Session
 	Ping(ctx context.Context) error
+	Query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(ctx context.Context, query string, args ...interface{}) *sql.Row
+	State() *State
+	Version() string
 }
 
 type Session interface {
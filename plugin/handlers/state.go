@@ -0,0 +1,50 @@
+package handlers
+
+import "sync"
+
+// State 保存同一连接在多次轮询之间需要保留的数据，例如高水位线和上次采样值
+// 与连接对象分开存放，连接因空闲被关闭并重建后数据仍然保留
+type State struct {
+	mu      sync.Mutex
+	entries map[string]*stateEntry
+}
+
+type stateEntry struct {
+	sync.Mutex
+	value interface{}
+}
+
+func NewState() *State {
+	return &State{entries: make(map[string]*stateEntry)}
+}
+
+func (s *State) entry(key string) *stateEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[key]
+	if !ok {
+		e = &stateEntry{}
+		s.entries[key] = e
+	}
+
+	return e
+}
+
+// Do 在持有key对应锁的情况下调用fn，fn返回的值作为新的状态保存
+// fn返回错误时保留原有状态，并发的同key调用将依次执行
+func (s *State) Do(key string, fn func(prev interface{}) (next interface{}, err error)) error {
+	e := s.entry(key)
+
+	e.Lock()
+	defer e.Unlock()
+
+	next, err := fn(e.value)
+	if err != nil {
+		return err
+	}
+
+	e.value = next
+
+	return nil
+}
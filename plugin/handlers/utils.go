@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"database/sql"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/godror/godror"
+)
+
+// nullInt64Ptr 将可能为NULL的整数列转换为指针，NULL对应JSON中的null
+func nullInt64Ptr(v sql.NullInt64) *int64 {
+	if !v.Valid {
+		return nil
+	}
+
+	return &v.Int64
+}
+
+// nullFloat64Ptr 将可能为NULL的数值列转换为指针，NULL对应JSON中的null
+func nullFloat64Ptr(v sql.NullFloat64) *float64 {
+	if !v.Valid {
+		return nil
+	}
+
+	return &v.Float64
+}
+
+// nullStringPtr 将可能为NULL的字符串列转换为指针，NULL对应JSON中的null
+func nullStringPtr(v sql.NullString) *string {
+	if !v.Valid {
+		return nil
+	}
+
+	return &v.String
+}
+
+// compileOptionalRegex 编译可选的正则表达式参数，空字符串返回nil
+func compileOptionalRegex(expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil
+	}
+
+	return regexp.Compile(expr)
+}
+
+const (
+	ModeLocal   = "local"
+	ModeCluster = "cluster"
+)
+
+// clusterView 返回实例号列表达式和视图名
+// 集群模式查询GV$视图并按inst_id分组，本地模式只查询当前实例的V$视图
+func clusterView(mode, view string) (instCol, from string) {
+	if mode == ModeCluster {
+		return "inst_id", "gv$" + view
+	}
+
+	return "TO_NUMBER(SYS_CONTEXT('USERENV', 'INSTANCE'))", "v$" + view
+}
+
+// byInstance 本地模式返回当前实例的结果，集群模式返回按inst_id分组的全部结果
+func byInstance(mode string, res map[string]interface{}) interface{} {
+	if mode == ModeCluster {
+		return res
+	}
+
+	for _, v := range res {
+		return v
+	}
+
+	return struct{}{}
+}
+
+// fetchRows 读取结果集的全部行，每行以小写列名为键，列值由columnValue转换
+func fetchRows(rows *sql.Rows) (columns []string, res []map[string]interface{}, err error) {
+	columns, err = rows.Columns()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for i := range columns {
+		columns[i] = strings.ToLower(columns[i])
+	}
+
+	res = make([]map[string]interface{}, 0)
+
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		ptrs := make([]interface{}, len(columns))
+
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+
+		if err = rows.Scan(ptrs...); err != nil {
+			return nil, nil, err
+		}
+
+		row := make(map[string]interface{}, len(columns))
+
+		for i, col := range columns {
+			row[col] = columnValue(values[i])
+		}
+
+		res = append(res, row)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	return columns, res, nil
+}
+
+// columnValue 将驱动返回的列值转换为可直接编码为JSON的类型
+// RAW列转换为字符串，NUMBER列转换为int64，带小数或超出int64范围时转换为float64
+func columnValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case []byte:
+		return string(val)
+	case godror.Number:
+		if i, err := strconv.ParseInt(string(val), 10, 64); err == nil {
+			return i
+		}
+
+		if f, err := strconv.ParseFloat(string(val), 64); err == nil {
+			return f
+		}
+
+		return string(val)
+	default:
+		return val
+	}
+}
+
+// parseVersion 将形如19.0.0.0.0的版本号拆分为数字，无法解析的部分按0处理
+func parseVersion(version string) []int {
+	parts := strings.Split(strings.TrimSpace(version), ".")
+	res := make([]int, len(parts))
+
+	for i, p := range parts {
+		res[i], _ = strconv.Atoi(p)
+	}
+
+	return res
+}
+
+// versionLess 逐段比较版本号，缺少的段按0处理
+func versionLess(a, b []int) bool {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y int
+
+		if i < len(a) {
+			x = a[i]
+		}
+
+		if i < len(b) {
+			y = b[i]
+		}
+
+		if x != y {
+			return x < y
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/godror/godror"
+)
+
+func TestColumnValue(t *testing.T) {
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		in   interface{}
+		want interface{}
+	}{
+		{"integer", godror.Number("42"), int64(42)},
+		{"negative", godror.Number("-7"), int64(-7)},
+		{"decimal", godror.Number("12.5"), 12.5},
+		{"leading dot", godror.Number(".25"), 0.25},
+		{"beyond int64", godror.Number("123456789012345678901234567890"), 1.2345678901234568e+29},
+		{"raw", []byte("0A1B"), "0A1B"},
+		{"string", "text", "text"},
+		{"null", nil, nil},
+		{"date", ts, ts},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := columnValue(tt.in); got != tt.want {
+				t.Errorf("columnValue(%#v) = %#v, want %#v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestColumnValueJSON(t *testing.T) {
+	row := map[string]interface{}{
+		"count": columnValue(godror.Number("3")),
+		"ratio": columnValue(godror.Number("0.5")),
+	}
+
+	got, err := json.Marshal(row)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := `{"count":3,"ratio":0.5}`; string(got) != want {
+		t.Errorf("json.Marshal() = %s, want %s", got, want)
+	}
+}
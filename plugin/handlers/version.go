@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"fmt"
+
+	"git.zabbix.com/ap/plugin-support/zbxerr"
+)
+
+type queryVariant struct {
+	since   string
+	version []int
+	query   string
+}
+
+// VersionedQuery 保存同一查询在不同Oracle版本上的写法，按服务器版本选择适用的变体
+type VersionedQuery struct {
+	name     string
+	variants []queryVariant
+}
+
+// NewVersionedQuery 创建没有任何变体的查询，name用于错误信息
+func NewVersionedQuery(name string) *VersionedQuery {
+	return &VersionedQuery{name: name}
+}
+
+// Since 注册从给定版本起适用的查询写法，变体须按版本从低到高注册
+func (q *VersionedQuery) Since(version, query string) *VersionedQuery {
+	q.variants = append(q.variants, queryVariant{since: version, version: parseVersion(version), query: query})
+
+	return q
+}
+
+// For 返回适用于给定服务器版本的最新变体，版本低于全部变体时返回不支持的指标
+// 版本未知时使用最旧的变体，新版本的写法在旧版本上可能无法执行
+func (q *VersionedQuery) For(version string) (string, error) {
+	if len(q.variants) == 0 {
+		return "", zbxerr.ErrorUnsupportedMetric
+	}
+
+	if version == "" {
+		Logger.Debugf("server version is unknown, using the %s query for Oracle %s", q.name, q.variants[0].since)
+
+		return q.variants[0].query, nil
+	}
+
+	v := parseVersion(version)
+
+	for i := len(q.variants) - 1; i >= 0; i-- {
+		if !versionLess(v, q.variants[i].version) {
+			return q.variants[i].query, nil
+		}
+	}
+
+	return "", unsupportedVersion(q.name, version)
+}
+
+// unsupportedVersion 返回说明给定功能在该服务器版本上不可用的错误
+func unsupportedVersion(name, version string) error {
+	return zbxerr.ErrorUnsupportedMetric.Wrap(fmt.Errorf("%s is not supported on Oracle %s", name, version))
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"errors"
+	"testing"
+
+	"git.zabbix.com/ap/plugin-support/zbxerr"
+)
+
+func TestVersionedQueryFor(t *testing.T) {
+	Logger = testLogger{t}
+
+	q := NewVersionedQuery("test").
+		Since("11.2", "q11").
+		Since("12.1", "q12").
+		Since("18", "q18")
+
+	tests := []struct {
+		version string
+		want    string
+		wantErr bool
+	}{
+		{"11.2.0.4.0", "q11", false},
+		{"12.1.0.2.0", "q12", false},
+		{"12.2.0.1.0", "q12", false},
+		{"19.0.0.0.0", "q18", false},
+		{"", "q11", false},
+		{"10.2.0.5.0", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := q.For(tt.version)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("For(%q) error = %v, wantErr %v", tt.version, err, tt.wantErr)
+		}
+
+		if err != nil && !errors.Is(err, zbxerr.ErrorUnsupportedMetric) {
+			t.Errorf("For(%q) error = %v, want %v", tt.version, err, zbxerr.ErrorUnsupportedMetric)
+		}
+
+		if got != tt.want {
+			t.Errorf("For(%q) = %q, want %q", tt.version, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+/*
+** Zabbix
+** Copyright 2001-2022 Zabbix SIA
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+**     http://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+**/
+
+package plugin
+
+import (
+	"context"
+	"golang.zabbix.com/sdk/zbxerr"
+	"sync/atomic"
+)
+
+// 每个查询槽位允许排队等待的请求数，超过后新请求立即失败
+// 修改时需同步更新Session.MaxConcurrentQueries的说明
+const queuedPerSlot = 4
+
+var errTooManyQueries = zbxerr.New("too many concurrent queries")
+
+// queryLimiter 限制同一连接地址同时执行的查询数，超出的请求排队等待空闲槽位
+type queryLimiter struct {
+	slots      chan struct{}
+	waiting    int32
+	maxWaiting int32
+}
+
+func newQueryLimiter(limit int) *queryLimiter {
+	return &queryLimiter{
+		slots:      make(chan struct{}, limit),
+		maxWaiting: int32(limit * queuedPerSlot),
+	}
+}
+
+// acquire 获取一个查询槽位，队列已满或在ctx截止前未获得槽位时返回errTooManyQueries
+func (l *queryLimiter) acquire(ctx context.Context) error {
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	default:
+	}
+
+	if atomic.AddInt32(&l.waiting, 1) > l.maxWaiting {
+		atomic.AddInt32(&l.waiting, -1)
+
+		return errTooManyQueries
+	}
+	defer atomic.AddInt32(&l.waiting, -1)
+
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return errTooManyQueries.Wrap(ctx.Err())
+	}
+}
+
+func (l *queryLimiter) release() {
+	<-l.slots
+}
+
+// acquire 按连接地址的MaxConcurrentQueries获取查询槽位，返回的函数用于释放槽位
+// 未限制并发的地址直接返回
+func (c *ConnManager) acquire(ctx context.Context, addr string) (func(), error) {
+	limit, ok := c.queryLimits[addr]
+	if !ok {
+		limit = c.defaultQueryLimit
+	}
+
+	if limit == 0 {
+		return func() {}, nil
+	}
+
+	c.Lock()
+
+	l, ok := c.limiters[addr]
+	if !ok {
+		l = newQueryLimiter(limit)
+		c.limiters[addr] = l
+	}
+
+	c.Unlock()
+
+	if err := l.acquire(ctx); err != nil {
+		return nil, err
+	}
+
+	return l.release, nil
+}
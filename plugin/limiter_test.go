@@ -0,0 +1,124 @@
+/*
+** Zabbix
+** Copyright 2001-2022 Zabbix SIA
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+**     http://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+**/
+
+package plugin
+
+import (
+	"context"
+	"errors"
+	"golang.zabbix.com/sdk/zbxerr"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestQueryLimiterQueueFull(t *testing.T) {
+	l := newQueryLimiter(1)
+
+	if err := l.acquire(context.Background()); err != nil {
+		t.Fatalf("acquire() = %v, want free slot", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	queued := make(chan error, queuedPerSlot)
+
+	for i := 0; i < queuedPerSlot; i++ {
+		go func() { queued <- l.acquire(ctx) }()
+	}
+
+	for atomic.LoadInt32(&l.waiting) < queuedPerSlot {
+		runtime.Gosched()
+	}
+
+	// 队列已满时不等待ctx截止，立即返回
+	err := l.acquire(context.Background())
+	if err != errTooManyQueries {
+		t.Errorf("acquire() with full queue = %v, want %v", err, errTooManyQueries)
+	}
+
+	// 释放槽位后由一个排队的请求获得
+	l.release()
+
+	if err = <-queued; err != nil {
+		t.Errorf("queued acquire() = %v, want slot", err)
+	}
+
+	cancel()
+
+	for i := 1; i < queuedPerSlot; i++ {
+		if err = <-queued; !errors.Is(err, errTooManyQueries) {
+			t.Errorf("cancelled acquire() = %v, want %v", err, errTooManyQueries)
+		}
+	}
+
+	if n := atomic.LoadInt32(&l.waiting); n != 0 {
+		t.Errorf("waiting = %d after all requests returned, want 0", n)
+	}
+}
+
+func TestQueryLimiterDeadline(t *testing.T) {
+	l := newQueryLimiter(1)
+
+	if err := l.acquire(context.Background()); err != nil {
+		t.Fatalf("acquire() = %v, want free slot", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := l.acquire(ctx)
+	if !errors.Is(err, errTooManyQueries) {
+		t.Fatalf("acquire() = %v, want %v", err, errTooManyQueries)
+	}
+
+	var zErr zbxerr.ZabbixError
+	if !errors.As(err, &zErr) || zErr.Cause() != context.DeadlineExceeded {
+		t.Errorf("acquire() cause = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	l.release()
+
+	if err = l.acquire(context.Background()); err != nil {
+		t.Errorf("acquire() after release = %v, want free slot", err)
+	}
+}
+
+func TestConnManagerAcquireUnlimited(t *testing.T) {
+	c := &ConnManager{queryLimits: map[string]int{"limited": 1}, limiters: make(map[string]*queryLimiter)}
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.acquire(context.Background(), "unlimited"); err != nil {
+			t.Fatalf("acquire() for unlimited address = %v", err)
+		}
+	}
+
+	release, err := c.acquire(context.Background(), "limited")
+	if err != nil {
+		t.Fatalf("acquire() = %v, want free slot", err)
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+
+	if _, err = c.acquire(ctx, "limited"); !errors.Is(err, errTooManyQueries) {
+		t.Errorf("acquire() over the limit = %v, want %v", err, errTooManyQueries)
+	}
+}
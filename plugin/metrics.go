@@ -25,11 +25,47 @@ import (
 )
 
 // handlerFunc defines an interface must be implemented by handlers.
-type handlerFunc func(ctx context.Context, s handlers.Database, params map[string]string) (res interface{}, err error)
+type handlerFunc func(ctx context.Context, s handlers.Database, params map[string]string,
+	extraParams ...string) (res interface{}, err error)
 
 var metricHandlers = map[string]handlerFunc{
-	keyTablespacesUsage: handlers.TablespacesUsageHandler,
-	keyPing:             handlers.PingHandler,
+	keyTablespacesUsage:  handlers.TablespacesUsageHandler,
+	keyPing:              handlers.PingHandler,
+	keyJobsStats:         handlers.JobsStatsHandler,
+	keyAlertLogErrors:    handlers.AlertLogErrorsHandler,
+	keySQLTop:            handlers.SQLTopHandler,
+	keyLongQueries:       handlers.LongQueriesHandler,
+	keyUndoStats:         handlers.UndoStatsHandler,
+	keySessionsStats:     handlers.SessionsStatsHandler,
+	keyWaitsStats:        handlers.WaitsStatsHandler,
+	keySysmetrics:        handlers.SysmetricsHandler,
+	keyRACInterconnect:   handlers.RACInterconnectHandler,
+	keyDatafilesIO:       handlers.DatafilesIOHandler,
+	keyOSStats:           handlers.OSStatsHandler,
+	keyParameters:        handlers.ParametersHandler,
+	keyParametersChanges: handlers.ParametersChangesHandler,
+	keyInventory:         handlers.InventoryHandler,
+	keyCursorsStats:      handlers.CursorsStatsHandler,
+	keyTempUsage:         handlers.TempUsageHandler,
+	keyFlashbackStats:    handlers.FlashbackStatsHandler,
+	keyResourceManager:   handlers.ResourceManagerHandler,
+	keyCustomQuery:       handlers.CustomQueryHandler,
+}
+
+// collectedMetrics lists keys that query expensive views and whose results may be shared between items.
+// Keys reporting changes since the previous poll are not listed, as background collection would consume them.
+var collectedMetrics = map[string]bool{
+	keyTablespacesUsage: true,
+	keyJobsStats:        true,
+	keyUndoStats:        true,
+	keySessionsStats:    true,
+	keyWaitsStats:       true,
+	keySysmetrics:       true,
+	keyInventory:        true,
+	keyCursorsStats:     true,
+	keyTempUsage:        true,
+	keyFlashbackStats:   true,
+	keyResourceManager:  true,
 }
 
 // getHandlerFunc returns a handlerFunc related to a given key.
@@ -38,19 +74,71 @@ func getHandlerFunc(key string) handlerFunc {
 }
 
 const (
-	keyTablespacesUsage = "oracle.tablespaces.usage"
-	keyPing             = "oracle.ping"
+	keyTablespacesUsage  = "oracle.tablespaces.usage"
+	keyPing              = "oracle.ping"
+	keyJobsStats         = "oracle.jobs.stats"
+	keyAlertLogErrors    = "oracle.alertlog.errors"
+	keySQLTop            = "oracle.sql.top"
+	keyLongQueries       = "oracle.queries.long"
+	keyUndoStats         = "oracle.undo.stats"
+	keySessionsStats     = "oracle.sessions.stats"
+	keyWaitsStats        = "oracle.waits.stats"
+	keySysmetrics        = "oracle.sysmetrics"
+	keyRACInterconnect   = "oracle.rac.interconnect"
+	keyDatafilesIO       = "oracle.datafiles.io"
+	keyOSStats           = "oracle.os.stats"
+	keyParameters        = "oracle.parameters"
+	keyParametersChanges = "oracle.parameters.changes"
+	keyInventory         = "oracle.inventory"
+	keyCursorsStats      = "oracle.cursors.stats"
+	keyTempUsage         = "oracle.temp.usage"
+	keyFlashbackStats    = "oracle.flashback.stats"
+	keyResourceManager   = "oracle.resource.manager"
+	keyCustomQuery       = "oracle.custom.query"
+	keyPluginStats       = "oracle.plugin.stats"
 )
 
 var (
-	paramURI = metric.NewConnParam("URI", "URI to connect or session name.")
+	paramURI          = metric.NewConnParam("URI", "URI to connect or session name.")
+	paramHours        = metric.NewParam("Hours", "Period in hours to count failed job runs over.").WithDefault("24").WithValidator(metric.RangeValidator{Min: 1, Max: 8760})
+	paramInclude      = metric.NewParam("Include", "Regular expression of ORA codes to report.")
+	paramExclude      = metric.NewParam("Exclude", "Regular expression of ORA codes to ignore.")
+	paramSQLTopMetric = metric.NewParam("Metric", "Dimension to sort statements by.").WithDefault(handlers.SQLTopElapsed).WithValidator(metric.SetValidator{Set: []string{handlers.SQLTopElapsed, handlers.SQLTopCPU, handlers.SQLTopBufferGets}})
+	paramN            = metric.NewParam("N", "Number of entries to return.").WithDefault("10").WithValidator(metric.RangeValidator{Min: 1, Max: 100})
+	paramSeconds      = metric.NewParam("Seconds", "Threshold in seconds for the current call of a session.").WithDefault("300").WithValidator(metric.RangeValidator{Min: 1, Max: 604800})
+	paramMode         = metric.NewParam("Mode", "Query the local instance or all cluster instances.").WithDefault(handlers.ModeLocal).WithValidator(metric.SetValidator{Set: []string{handlers.ModeLocal, handlers.ModeCluster}})
+	paramQueryName    = metric.NewParam("QueryName", "Name of the custom query file without extension.").SetRequired()
 )
 
 var metrics = metric.MetricSet{
-	keyTablespacesUsage: metric.New("Returns usage statistics for tablespaces.", []*metric.Param{paramURI}, false),
-	keyPing:             metric.New("Test if connection is alive or not.", []*metric.Param{paramURI}, false),
+	keyTablespacesUsage:  metric.New("Returns usage statistics and growth forecast for tablespaces.", []*metric.Param{paramURI}, false),
+	keyPing:              metric.New("Test if connection is alive or not.", []*metric.Param{paramURI}, false),
+	keyJobsStats:         metric.New("Returns scheduler and legacy jobs state and failed runs.", []*metric.Param{paramURI, paramHours}, false),
+	keyAlertLogErrors:    metric.New("Returns alert log errors appeared since the previous poll.", []*metric.Param{paramURI, paramInclude, paramExclude}, false),
+	keySQLTop:            metric.New("Returns top SQL statements by the chosen dimension over the last poll interval.", []*metric.Param{paramURI, paramSQLTopMetric, paramN}, false),
+	keyLongQueries:       metric.New("Returns active user sessions whose current call runs longer than the threshold.", []*metric.Param{paramURI, paramSeconds}, false),
+	keyUndoStats:         metric.New("Returns undo tablespace usage, retention and ORA-01555 statistics.", []*metric.Param{paramURI}, false),
+	keySessionsStats:     metric.New("Returns sessions statistics.", []*metric.Param{paramURI, paramMode}, false),
+	keyWaitsStats:        metric.New("Returns wait class statistics.", []*metric.Param{paramURI, paramMode}, false),
+	keySysmetrics:        metric.New("Returns system metrics of the 60 seconds interval.", []*metric.Param{paramURI, paramMode}, false),
+	keyRACInterconnect:   metric.New("Returns global cache blocks and average receive time per cluster instance.", []*metric.Param{paramURI}, false),
+	keyDatafilesIO:       metric.New("Returns datafiles and tempfiles I/O over the last poll interval and datafiles needing attention.", []*metric.Param{paramURI}, false),
+	keyOSStats:           metric.New("Returns host CPU, memory and swap statistics from v$osstat.", []*metric.Param{paramURI}, false),
+	keyParameters:        metric.New("Returns current and spfile values of the given parameters.", []*metric.Param{paramURI}, true),
+	keyParametersChanges: metric.New("Returns parameters changed since the previous poll or differing from the spfile.", []*metric.Param{paramURI}, false),
+	keyInventory:         metric.New("Returns database version, installed patches and components status.", []*metric.Param{paramURI}, false),
+	keyCursorsStats:      metric.New("Returns open and cached cursors usage and library cache statistics.", []*metric.Param{paramURI, paramN}, false),
+	keyTempUsage:         metric.New("Returns temporary tablespaces usage and top sessions by temporary space consumption.", []*metric.Param{paramURI, paramN}, false),
+	keyFlashbackStats:    metric.New("Returns flashback database window, log size and restore points.", []*metric.Param{paramURI}, false),
+	keyResourceManager:   metric.New("Returns the active resource plan and consumer groups statistics.", []*metric.Param{paramURI}, false),
+	keyCustomQuery:       metric.New("Returns the result of a custom query from the custom queries path.", []*metric.Param{paramURI, paramQueryName}, true),
+	keyPluginStats:       metric.New("Returns connection pool, query and cache statistics of the plugin itself.", []*metric.Param{}, false),
 }
 
 func init() {
+	if err := loadCatalogue(metrics, metricHandlers); err != nil {
+		panic(err)
+	}
+
 	plugin.RegisterMetrics(&Impl, Name, metrics.List()...)
 }
@@ -27,8 +27,9 @@ import (
 )
 
 const (
-	Name       = "Oracle"
-	hkInterval = 10
+	Name                   = "Oracle"
+	hkInterval             = 10
+	defaultCollectInterval = 60
 )
 
 // Plugin -
@@ -36,12 +37,13 @@ type Plugin struct {
 	plugin.Base
 	connMgr *ConnManager
 	options PluginOptions
+	calls   callGroup
 }
 
 var Impl Plugin
 
 func (p *Plugin) Export(key string, rawParams []string, pluginCtx plugin.ContextProvider) (result interface{}, err error) {
-	params, _, hc, err := metrics[key].EvalParams(rawParams, p.options.Sessions)
+	params, extraParams, hc, err := metrics[key].EvalParams(rawParams, p.options.Sessions)
 	if err != nil {
 		return nil, err
 	}
@@ -51,22 +53,59 @@ func (p *Plugin) Export(key string, rawParams []string, pluginCtx plugin.Context
 		return nil, err
 	}
 
+	if key == keyPluginStats {
+		return p.pluginStats()
+	}
+
 	handleMetric := getHandlerFunc(key)
 	if handleMetric == nil {
 		return nil, zbxerr.ErrorUnsupportedMetric
 	}
 
+	cacheable := p.cacheable(key)
+	id := requestKey(key, params, extraParams)
+
+	if cacheable {
+		if result, ok := p.connMgr.cache.get(id, time.Duration(p.options.MaxStaleness)*time.Second); ok {
+			p.connMgr.recordCacheHit(params["URI"])
+
+			return result, nil
+		}
+	}
+
+	// 同一键、参数和连接地址的并发请求共享同一次查询的结果
+	result, err, shared := p.calls.do(id, func() (interface{}, error) {
+		return p.execute(key, handleMetric, params, extraParams, pluginCtx.Timeout())
+	})
+	if shared {
+		p.Debugf("request %s for %s coalesced with an in-flight query, %d coalesced in total",
+			key, maskURI(params["URI"]), p.calls.Coalesced())
+	}
+
+	if err == nil && cacheable {
+		p.connMgr.cache.set(id, key, params, extraParams, result)
+	}
+
+	return result, err
+}
+
+// execute 获取连接并执行监控项的处理程序
+func (p *Plugin) execute(
+	key string, handleMetric handlerFunc, params map[string]string, extraParams []string, itemTimeout int,
+) (interface{}, error) {
 	// 获取连接
 	// 连接管理器负责创建和管理连接，确保每个连接在需要时可用。
 	// 连接管理器还负责定期检查连接的状态，并在必要时关闭未使用的连接。
 	conn, err := p.connMgr.GetConnection(params)
 	if err != nil {
 		// 如果请求mongodb.ping，则应使用处理连接错误的特殊逻辑，因为如果发生任何错误，它必须返回pingFailed
+		// 断路器打开时GetConnection不尝试连接，oracle.ping立即返回pingFailed
 		if key == keyPing {
 			p.Debugf(err.Error())
 			return handlers.PingFailed, nil
 		}
 		p.Errf(err.Error())
+		err, _ = classifyError(err)
 		return nil, err
 	}
 
@@ -74,31 +113,69 @@ func (p *Plugin) Export(key string, rawParams []string, pluginCtx plugin.Context
 
 	timeout := conn.getTimeout()
 
-	if timeout < time.Second*time.Duration(pluginCtx.Timeout()) {
-		timeout = time.Second * time.Duration(pluginCtx.Timeout())
+	if timeout < time.Second*time.Duration(itemTimeout) {
+		timeout = time.Second * time.Duration(itemTimeout)
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
-	result, err = handleMetric(ctx, conn, params)
+
+	release, err := p.connMgr.acquire(ctx, conn.addr)
+	if err != nil {
+		p.Errf(err.Error())
+
+		return nil, err
+	}
+	defer release()
+
+	start := time.Now()
+	result, err := handleMetric(ctx, conn, params, extraParams...)
+	p.connMgr.recordQuery(conn.addr, key, time.Since(start), err)
+
 	if err != nil {
 		p.Errf(err.Error())
+
+		// 认证失败和网络错误说明连接已不可用，移除后下次请求重新连接
+		classified, evict := classifyError(err)
+		if evict {
+			p.connMgr.evict(conn.addr)
+		}
+
+		return nil, classified
 	}
 
-	return result, err
+	// 连接不可用时移除，下次请求重新连接
+	if key == keyPing && result == handlers.PingFailed {
+		p.connMgr.evict(conn.addr)
+	}
+
+	return result, nil
 }
 
 // Start 实现Runner接口，并在插件激活时执行初始化。
 func (p *Plugin) Start() {
 	handlers.Logger = p.Logger
+
+	if p.options.CustomQueriesPath != "" {
+		handlers.CustomQueries = handlers.NewQueryStorage(p.options.CustomQueriesPath)
+	}
+
+	queryLimits, defaultQueryLimit := p.options.queryLimits()
+
 	p.connMgr = NewConnManager(
 		time.Duration(p.options.KeepAlive)*time.Second,
 		time.Duration(p.options.Timeout)*time.Second,
 		hkInterval*time.Second,
+		time.Duration(p.options.CollectInterval)*time.Second,
+		handlers.CustomQueries,
+		queryLimits,
+		defaultQueryLimit,
 	)
 }
 
 // Stop 实现Runner接口，并在插件停用时释放资源。
 func (p *Plugin) Stop() {
+	p.connMgr.Destroy()
 	p.connMgr = nil
+	handlers.CustomQueries = nil
 }
@@ -0,0 +1,271 @@
+/*
+** Zabbix
+** Copyright 2001-2022 Zabbix SIA
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+**     http://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+**/
+
+package plugin
+
+import (
+	"database/sql"
+	"encoding/json"
+	"golang.zabbix.com/sdk/zbxerr"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// latencySamples 每个键保留最近的耗时样本数，用于计算p95
+const latencySamples = 1024
+
+var (
+	oraCodeRegex  = regexp.MustCompile(`ORA-\d{5}`)
+	passwordRegex = regexp.MustCompile(`(?i)(password\s*=\s*)("[^"]*"|\S+)`)
+)
+
+// keyLatency 一个键的查询次数、总耗时和最近的耗时样本
+type keyLatency struct {
+	count   int64
+	total   time.Duration
+	samples []time.Duration
+	next    int
+}
+
+func (l *keyLatency) add(d time.Duration) {
+	l.count++
+	l.total += d
+
+	if len(l.samples) < latencySamples {
+		l.samples = append(l.samples, d)
+
+		return
+	}
+
+	l.samples[l.next] = d
+	l.next = (l.next + 1) % latencySamples
+}
+
+func (l *keyLatency) p95() time.Duration {
+	if len(l.samples) == 0 {
+		return 0
+	}
+
+	sorted := make([]time.Duration, len(l.samples))
+	copy(sorted, l.samples)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	return sorted[(len(sorted)*95+99)/100-1]
+}
+
+// addrStats 一个连接地址的连接、查询和缓存计数
+type addrStats struct {
+	connects        int64
+	connectFailures int64
+	queries         int64
+	cacheHits       int64
+	errors          map[string]int64
+	latency         map[string]*keyLatency
+}
+
+type keyLatencyStats struct {
+	Count      int64   `json:"count"`
+	AvgLatency float64 `json:"avg_latency"`
+	P95Latency float64 `json:"p95_latency"`
+}
+
+type uriStats struct {
+	Sessions         []string                   `json:"sessions"`
+	OpenConnections  int                        `json:"open_connections"`
+	IdleConnections  int                        `json:"idle_connections"`
+	InUseConnections int                        `json:"in_use_connections"`
+	Connects         int64                      `json:"connects"`
+	ConnectFailures  int64                      `json:"connect_failures"`
+	Queries          int64                      `json:"queries"`
+	Errors           map[string]int64           `json:"errors"`
+	CacheHits        int64                      `json:"cache_hits"`
+	Circuit          string                     `json:"circuit"`
+	Keys             map[string]keyLatencyStats `json:"keys"`
+}
+
+type pluginStats struct {
+	Coalesced uint64              `json:"coalesced"`
+	URIs      map[string]uriStats `json:"uris"`
+}
+
+// addrStats 返回给定地址的计数，调用方须持有c.Mutex
+func (c *ConnManager) addrStats(addr string) *addrStats {
+	s, ok := c.stats[addr]
+	if !ok {
+		s = &addrStats{errors: make(map[string]int64), latency: make(map[string]*keyLatency)}
+		c.stats[addr] = s
+	}
+
+	return s
+}
+
+// recordConnect 记录一次建立连接的结果
+func (c *ConnManager) recordConnect(addr string, err error) {
+	c.Lock()
+	defer c.Unlock()
+
+	s := c.addrStats(addr)
+
+	if err != nil {
+		s.connectFailures++
+
+		return
+	}
+
+	s.connects++
+}
+
+// recordQuery 记录一次监控项查询的耗时和错误，错误按ORA代码分类，无ORA代码的错误计入other
+func (c *ConnManager) recordQuery(addr, key string, d time.Duration, err error) {
+	c.Lock()
+	defer c.Unlock()
+
+	s := c.addrStats(addr)
+	s.queries++
+
+	l, ok := s.latency[key]
+	if !ok {
+		l = &keyLatency{}
+		s.latency[key] = l
+	}
+
+	l.add(d)
+
+	if err != nil {
+		code := oraCode(err)
+		if code == "" {
+			code = "other"
+		}
+
+		s.errors[code]++
+	}
+}
+
+// recordCacheHit 记录一次由后台采集缓存返回的请求
+func (c *ConnManager) recordCacheHit(addr string) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.addrStats(addr).cacheHits++
+}
+
+// pluginStats 返回插件自身的连接池和查询统计，按URI分组，URI中的密码被隐藏
+func (p *Plugin) pluginStats() (interface{}, error) {
+	res := pluginStats{Coalesced: p.calls.Coalesced(), URIs: make(map[string]uriStats)}
+
+	sessions := make(map[string][]string)
+	for name, session := range p.options.Sessions {
+		sessions[session.URI] = append(sessions[session.URI], name)
+	}
+
+	c := p.connMgr
+	now := time.Now()
+
+	// 只在复制连接池和断路器状态时持有connMutex，不等待正在进行的连接尝试
+	c.connMutex.Lock()
+
+	dbStats := make(map[string]sql.DBStats, len(c.connections))
+	for addr, conn := range c.connections {
+		dbStats[addr] = conn.session.Stats()
+	}
+
+	circuits := make(map[string]string, len(c.breakers))
+	for addr, b := range c.breakers {
+		circuits[addr] = b.state(now)
+	}
+
+	c.connMutex.Unlock()
+
+	c.Lock()
+
+	addrs := make(map[string]bool)
+	for addr := range c.stats {
+		addrs[addr] = true
+	}
+
+	for addr := range sessions {
+		addrs[addr] = true
+	}
+
+	for addr := range addrs {
+		u := uriStats{
+			Sessions: sessions[addr],
+			Errors:   make(map[string]int64),
+			Circuit:  circuitClosed,
+			Keys:     make(map[string]keyLatencyStats),
+		}
+
+		if u.Sessions == nil {
+			u.Sessions = make([]string, 0)
+		}
+
+		sort.Strings(u.Sessions)
+
+		if s, ok := dbStats[addr]; ok {
+			u.OpenConnections = s.OpenConnections
+			u.IdleConnections = s.Idle
+			u.InUseConnections = s.InUse
+		}
+
+		if circuit, ok := circuits[addr]; ok {
+			u.Circuit = circuit
+		}
+
+		if s, ok := c.stats[addr]; ok {
+			u.Connects = s.connects
+			u.ConnectFailures = s.connectFailures
+			u.Queries = s.queries
+			u.CacheHits = s.cacheHits
+
+			for code, n := range s.errors {
+				u.Errors[code] = n
+			}
+
+			for key, l := range s.latency {
+				u.Keys[key] = keyLatencyStats{
+					Count:      l.count,
+					AvgLatency: (l.total / time.Duration(l.count)).Seconds(),
+					P95Latency: l.p95().Seconds(),
+				}
+			}
+		}
+
+		res.URIs[maskURI(addr)] = u
+	}
+
+	c.Unlock()
+
+	jsonRes, err := json.Marshal(res)
+	if err != nil {
+		return nil, zbxerr.ErrorCannotMarshalJSON.Wrap(err)
+	}
+
+	return string(jsonRes), nil
+}
+
+// maskURI 隐藏连接字符串中的密码，支持user/password@connect和password=参数两种形式
+func maskURI(addr string) string {
+	if i := strings.LastIndex(addr, "@"); i >= 0 && !strings.Contains(addr[:i], "=") {
+		if j := strings.Index(addr[:i], "/"); j >= 0 {
+			return addr[:j] + "/***" + addr[i:]
+		}
+	}
+
+	return passwordRegex.ReplaceAllString(addr, "${1}***")
+}
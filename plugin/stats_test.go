@@ -0,0 +1,185 @@
+/*
+** Zabbix
+** Copyright 2001-2022 Zabbix SIA
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+**     http://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+**/
+
+package plugin
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMaskURI(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"easy connect", "zabbix/secret@db1:1521/ORCL", "zabbix/***@db1:1521/ORCL"},
+		{"password with at sign", "zabbix/p@ss@db1/ORCL", "zabbix/***@db1/ORCL"},
+		{"no password", "zabbix@db1/ORCL", "zabbix@db1/ORCL"},
+		{"tns alias", "ORCL", "ORCL"},
+		{"password parameter", `user=zabbix password=secret connectString=db1/ORCL`,
+			`user=zabbix password=*** connectString=db1/ORCL`},
+		{"quoted password", `user="zabbix" password="se cret" connectString="db1/ORCL"`,
+			`user="zabbix" password=*** connectString="db1/ORCL"`},
+		{"spaced uppercase", `user=zabbix PASSWORD = secret`, `user=zabbix PASSWORD = ***`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := maskURI(tt.in)
+			if got != tt.want {
+				t.Errorf("maskURI(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+
+			if strings.Contains(got, "secret") || strings.Contains(got, "se cret") {
+				t.Errorf("maskURI(%q) = %q leaks the password", tt.in, got)
+			}
+		})
+	}
+}
+
+func TestKeyLatencyP95(t *testing.T) {
+	var l keyLatency
+
+	if p := l.p95(); p != 0 {
+		t.Errorf("p95() without samples = %s, want 0", p)
+	}
+
+	for i := 1; i <= 100; i++ {
+		l.add(time.Duration(i) * time.Millisecond)
+	}
+
+	if p := l.p95(); p != 95*time.Millisecond {
+		t.Errorf("p95() of 1..100ms = %s, want 95ms", p)
+	}
+
+	// 样本写满后从最旧的样本开始覆盖
+	for i := len(l.samples); i < latencySamples; i++ {
+		l.add(time.Second)
+	}
+
+	for i := 0; i < latencySamples-10; i++ {
+		l.add(time.Millisecond)
+	}
+
+	if len(l.samples) != latencySamples {
+		t.Fatalf("len(samples) = %d, want %d", len(l.samples), latencySamples)
+	}
+
+	if l.next != latencySamples-10 {
+		t.Errorf("next = %d, want %d", l.next, latencySamples-10)
+	}
+
+	if p := l.p95(); p != time.Millisecond {
+		t.Errorf("p95() after overwrite = %s, want 1ms", p)
+	}
+
+	for i := 0; i < 20; i++ {
+		l.add(time.Millisecond)
+	}
+
+	if l.next != 10 {
+		t.Errorf("next after wrap-around = %d, want 10", l.next)
+	}
+
+	if want := int64(100 + latencySamples - 100 + latencySamples - 10 + 20); l.count != want {
+		t.Errorf("count = %d, want %d", l.count, want)
+	}
+}
+
+func TestPluginStatsByURI(t *testing.T) {
+	const (
+		shared = "zabbix/secret@db1/ORCL"
+		other  = "zabbix/secret@db2/ORCL"
+		unused = "zabbix/secret@db3/ORCL"
+	)
+
+	p := &Plugin{
+		options: PluginOptions{Sessions: map[string]Session{
+			"prod":    {URI: shared},
+			"prod_ro": {URI: shared},
+			"dr":      {URI: other},
+			"spare":   {URI: unused},
+		}},
+		connMgr: &ConnManager{
+			connections: make(map[string]*OracleConn),
+			breakers:    make(map[string]*circuitBreaker),
+			stats:       make(map[string]*addrStats),
+		},
+	}
+
+	c := p.connMgr
+	c.recordConnect(shared, nil)
+	c.recordQuery(shared, keyPing, 10*time.Millisecond, nil)
+	c.recordQuery(shared, keyPing, 30*time.Millisecond, errors.New("ORA-01013: user requested cancel"))
+	c.recordQuery(shared, keySQLTop, time.Millisecond, errors.New("no data"))
+	c.recordCacheHit(shared)
+	c.recordConnect(other, errors.New("ORA-12541: TNS:no listener"))
+
+	b := &circuitBreaker{}
+	fail(b, breakerThreshold, time.Now())
+	c.breakers[other] = b
+
+	raw, err := p.pluginStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(raw.(string), "secret") {
+		t.Fatalf("pluginStats() leaks a password: %s", raw)
+	}
+
+	var res pluginStats
+	if err = json.Unmarshal([]byte(raw.(string)), &res); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res.URIs) != 3 {
+		t.Fatalf("pluginStats() has %d URIs, want 3", len(res.URIs))
+	}
+
+	s := res.URIs[maskURI(shared)]
+
+	if strings.Join(s.Sessions, ",") != "prod,prod_ro" {
+		t.Errorf("sessions = %v, want [prod prod_ro]", s.Sessions)
+	}
+
+	if s.Connects != 1 || s.Queries != 3 || s.CacheHits != 1 || s.Circuit != circuitClosed {
+		t.Errorf("shared URI stats = %+v", s)
+	}
+
+	if s.Errors["ORA-01013"] != 1 || s.Errors["other"] != 1 {
+		t.Errorf("errors = %v, want one ORA-01013 and one other", s.Errors)
+	}
+
+	if k := s.Keys[keyPing]; k.Count != 2 || k.AvgLatency != 0.02 || k.P95Latency != 0.03 {
+		t.Errorf("%s latency = %+v", keyPing, k)
+	}
+
+	if s := res.URIs[maskURI(other)]; s.ConnectFailures != 1 || s.Circuit != circuitOpen ||
+		strings.Join(s.Sessions, ",") != "dr" {
+		t.Errorf("other URI stats = %+v", s)
+	}
+
+	if s := res.URIs[maskURI(unused)]; s.Queries != 0 || strings.Join(s.Sessions, ",") != "spare" {
+		t.Errorf("unused URI stats = %+v", s)
+	}
+}